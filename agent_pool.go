@@ -0,0 +1,219 @@
+package wxcom
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoHealthyAgent is returned when all the agents of the pool are cooling down.
+var ErrNoHealthyAgent = errors.New("no healthy agent is available")
+
+// ErrAllAgentsFailed is matched by the AgentsFailedError, see errors.Is.
+var ErrAllAgentsFailed = errors.New("all the agents have failed")
+
+// AgentsFailedError struct is returned when every healthy agent of the pool has failed to send.
+// Err is the error of the last agent, such as the ApiError.
+type AgentsFailedError struct {
+	Err error
+}
+
+// Error method implements the error interface.
+func (e *AgentsFailedError) Error() string {
+	return ErrAllAgentsFailed.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap method returns the error of the last agent.
+func (e *AgentsFailedError) Unwrap() error {
+	return e.Err
+}
+
+// Is method reports whether the target is ErrAllAgentsFailed.
+func (e *AgentsFailedError) Is(target error) bool {
+	return target == ErrAllAgentsFailed
+}
+
+// AgentPool struct is used to send messages by several agents of the same corp,
+// and fail over to the next healthy agent when the agent cannot send.
+//
+// The agent fails over when WeCom returns the errors of the agent itself, such as the quota exhausted(45009),
+// the secret invalid or revoked(40001), the agent invalid(40056), the api forbidden(48002), the ip not allowed(60020)
+// and the agent not permitted(301002), and the agent cools down before it is used again.
+// The send is not failed over when some batches have been sent, so that the recipients do not get the message twice.
+type AgentPool struct {
+	cooldown   time.Duration
+	onFailover func(from, to int, err error)
+
+	mu     sync.Mutex
+	agents []*pooledAgent
+}
+
+// pooledAgent struct holds the health of an agent in the pool.
+type pooledAgent struct {
+	wx        *Wxcom
+	coolUntil time.Time
+	failures  int
+	lastError string
+}
+
+// AgentHealth struct holds the health of an agent in the pool.
+type AgentHealth struct {
+	Agentid   int
+	Healthy   bool
+	CoolUntil time.Time
+	Failures  int
+	LastError string
+}
+
+// NewAgentPool method creates a new AgentPool instance of the agents, the agents are tried in order.
+// By default, the failed agent cools down for 5 minutes.
+func NewAgentPool(agents ...*Wxcom) *AgentPool {
+	p := &AgentPool{cooldown: 5 * time.Minute}
+	for _, wx := range agents {
+		p.agents = append(p.agents, &pooledAgent{wx: wx})
+	}
+	return p
+}
+
+// SetCooldown method sets how long the failed agent is not used.
+func (p *AgentPool) SetCooldown(cooldown time.Duration) *AgentPool {
+	p.cooldown = cooldown
+	return p
+}
+
+// OnFailover method sets the callback when the send fails over from an agent to another one.
+func (p *AgentPool) OnFailover(fn func(from, to int, err error)) *AgentPool {
+	p.onFailover = fn
+	return p
+}
+
+// Health method returns the health of the agents in order.
+func (p *AgentPool) Health() []AgentHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	health := make([]AgentHealth, len(p.agents))
+	for i, agent := range p.agents {
+		health[i] = AgentHealth{
+			Agentid:   agent.wx.agentid,
+			Healthy:   !now.Before(agent.coolUntil),
+			CoolUntil: agent.coolUntil,
+			Failures:  agent.failures,
+			LastError: agent.lastError,
+		}
+	}
+	return health
+}
+
+// Send method sends the message by the first healthy agent, and fails over to the next healthy agent.
+// The message is composed by any client, and the agentid of the sending agent is used.
+// If every healthy agent has failed, the AgentsFailedError is returned with the response of the last agent.
+func (p *AgentPool) Send(s Sendable) (*RespMessage, error) {
+	if len(p.agents) == 0 {
+		return nil, ErrNoHealthyAgent
+	}
+
+	msg, err := buildMessage(p.agents[0].wx.M(), s)
+	if err != nil {
+		return nil, err
+	}
+	if msg.target.kind == targetRobot {
+		return nil, errors.New("robot message cannot be sent by agent pool")
+	}
+
+	var resp *RespMessage
+	from := -1
+	for i, agent := range p.agents {
+		if !p.healthy(agent) {
+			continue
+		}
+		if from >= 0 && p.onFailover != nil {
+			p.onFailover(p.agents[from].wx.agentid, agent.wx.agentid, err)
+		}
+
+		attempt := msg.clone()
+		attempt.wx = agent.wx
+		resp, err = attempt.send(sendOptions{dedupSender: p.dedupSender()})
+
+		failure := agentFailure(resp, err)
+		if failure == nil {
+			p.succeed(agent)
+			return resp, err
+		}
+		p.fail(agent, failure)
+
+		if resp != nil && resp.partlyDelivered() {
+			// some batches or parts have been sent
+			return resp, err
+		}
+		if err == nil {
+			err = failure
+		}
+		from = i
+	}
+
+	if from < 0 {
+		return nil, ErrNoHealthyAgent
+	}
+	return resp, &AgentsFailedError{Err: err}
+}
+
+// dedupSender method returns the sender shared by the agents of the pool to deduplicate the messages.
+func (p *AgentPool) dedupSender() string {
+	agents := make([]string, len(p.agents))
+	for i, agent := range p.agents {
+		agents[i] = fmt.Sprintf("%s:%d", agent.wx.corpid, agent.wx.agentid)
+	}
+	return "agentpool:" + strings.Join(agents, "|")
+}
+
+// healthy method reports whether the agent is not cooling down.
+func (p *AgentPool) healthy(agent *pooledAgent) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return !time.Now().Before(agent.coolUntil)
+}
+
+// fail method cools down the agent.
+func (p *AgentPool) fail(agent *pooledAgent, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	agent.coolUntil = time.Now().Add(p.cooldown)
+	agent.failures++
+	agent.lastError = err.Error()
+}
+
+// succeed method resets the failures of the agent.
+func (p *AgentPool) succeed(agent *pooledAgent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	agent.failures = 0
+	agent.lastError = ""
+}
+
+// agentFailure method returns the error of the agent itself, nil if the send has not failed by the agent.
+func agentFailure(resp *RespMessage, err error) error {
+	var apiErr *ApiError
+	if err != nil && !errors.As(err, &apiErr) {
+		return nil
+	}
+	if apiErr == nil && resp != nil {
+		apiErr, _ = resp.err().(*ApiError)
+	}
+	if apiErr == nil {
+		return nil
+	}
+
+	switch apiErr.Errcode {
+	case 40001, 40056, 45009, 48002, 60020, 301002:
+		return apiErr
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,192 @@
+package wxcom_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mingzaily/go-wxcom"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func createAgentServer(t *testing.T) *httptest.Server {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		t.Logf("Path: %v", r.URL.Path)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			if r.URL.Query().Get("corpsecret") == "revoked" {
+				_, _ = w.Write([]byte("{\"errcode\":40001,\"errmsg\":\"invalid credential\"}"))
+				return
+			}
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"access_token\":\"token\",\"expires_in\":7200}"))
+		case "/cgi-bin/message/send":
+			body, _ := ioutil.ReadAll(r.Body)
+			switch {
+			case strings.Contains(string(body), "\"agentid\":2,"):
+				_, _ = w.Write([]byte("{\"errcode\":45009,\"errmsg\":\"api freq out of limit\"}"))
+			case strings.Contains(string(body), "invalid_user"):
+				_, _ = w.Write([]byte("{\"errcode\":81013,\"errmsg\":\"user & party & tag all invalid\"}"))
+			default:
+				_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"msgid\":\"msgid\"}"))
+			}
+		}
+	}
+
+	return httptest.NewServer(http.HandlerFunc(fn))
+}
+
+func createTestAgent(ts string, corpsecret string, agentid int) *wxcom.Wxcom {
+	agent := wxcom.New("corp", corpsecret, agentid)
+	agent.Resty.SetBaseURL(ts)
+	return agent
+}
+
+func TestAgentPool_Send(t *testing.T) {
+	ts := createAgentServer(t)
+	defer ts.Close()
+
+	var failovers [][2]int
+	pool := wxcom.NewAgentPool(
+		createTestAgent(ts.URL, "revoked", 1),
+		createTestAgent(ts.URL, "secret", 2),
+		createTestAgent(ts.URL, "secret", 3),
+	).OnFailover(func(from, to int, err error) {
+		failovers = append(failovers, [2]int{from, to})
+	})
+
+	resp, err := pool.Send(wx.M().ToUser([]string{"user"}).Text("content"))
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+	assertEqual(t, resp.Msgid, "msgid")
+	assertEqual(t, failovers, [][2]int{{1, 2}, {2, 3}})
+
+	health := pool.Health()
+	assertEqual(t, health[0].Healthy, false)
+	assertEqual(t, health[0].LastError, "wxcom: errcode 40001, errmsg invalid credential")
+	assertEqual(t, health[1].Healthy, false)
+	assertEqual(t, health[1].Failures, 1)
+	assertEqual(t, health[2].Healthy, true)
+
+	// the cooling agents are skipped
+	failovers = nil
+	_, err = pool.Send(wx.M().ToUser([]string{"user"}).Text("content"))
+	assertEqual(t, err, nil)
+	assertEqual(t, len(failovers), 0)
+
+	// the error not caused by the agent is not failed over
+	resp, err = pool.Send(wx.M().ToUser([]string{"invalid_user"}).Text("content"))
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 81013)
+	assertEqual(t, pool.Health()[2].Healthy, true)
+}
+
+func TestAgentPool_NoHealthyAgent(t *testing.T) {
+	ts := createAgentServer(t)
+	defer ts.Close()
+
+	pool := wxcom.NewAgentPool(createTestAgent(ts.URL, "secret", 2)).SetCooldown(100 * time.Millisecond)
+
+	resp, err := pool.Send(wx.M().ToUser([]string{"user"}).Text("content"))
+	assertEqual(t, errors.Is(err, wxcom.ErrAllAgentsFailed), true)
+	assertEqual(t, resp.Errcode, 45009)
+
+	_, err = pool.Send(wx.M().ToUser([]string{"user"}).Text("content"))
+	assertEqual(t, err, wxcom.ErrNoHealthyAgent)
+
+	time.Sleep(100 * time.Millisecond)
+	resp, err = pool.Send(wx.M().ToUser([]string{"user"}).Text("content"))
+	var apiErr *wxcom.ApiError
+	assertEqual(t, errors.As(err, &apiErr), true)
+	assertEqual(t, apiErr.Errcode, 45009)
+	assertEqual(t, resp.Errcode, 45009)
+}
+
+func TestAgentPool_AllAgentsFailed(t *testing.T) {
+	ts := createAgentServer(t)
+	defer ts.Close()
+
+	pool := wxcom.NewAgentPool(createTestAgent(ts.URL, "revoked", 1), createTestAgent(ts.URL, "revoked", 3))
+
+	resp, err := pool.Send(wx.M().ToUser([]string{"user"}).Text("content"))
+	assertEqual(t, resp == nil, true)
+	assertEqual(t, errors.Is(err, wxcom.ErrAllAgentsFailed), true)
+	assertEqual(t, err.Error(), "all the agents have failed: wxcom: errcode 40001, errmsg invalid credential")
+}
+
+func TestAgentPool_Dedup(t *testing.T) {
+	ts := createAgentServer(t)
+	defer ts.Close()
+
+	store := wxcom.NewMemoryDedupStore()
+
+	// the first agent of the replica has been revoked, the message is sent by the next agent
+	replica := wxcom.NewAgentPool(
+		createTestAgent(ts.URL, "revoked", 1).SetDeduplicator(store, time.Minute),
+		createTestAgent(ts.URL, "secret", 3).SetDeduplicator(store, time.Minute),
+	)
+	resp, err := replica.Send(wxcom.New("corp", "secret", 0).M().ToUser([]string{"user"}).IdempotencyKey("alert-1").Text("content"))
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Msgid, "msgid")
+
+	// the other replica sending by the first agent is suppressed
+	pool := wxcom.NewAgentPool(
+		createTestAgent(ts.URL, "secret", 1).SetDeduplicator(store, time.Minute),
+		createTestAgent(ts.URL, "secret", 3).SetDeduplicator(store, time.Minute),
+	)
+	_, err = pool.Send(wxcom.New("corp", "secret", 0).M().ToUser([]string{"user"}).IdempotencyKey("alert-1").Text("content"))
+	var duplicateErr *wxcom.DuplicateError
+	assertEqual(t, errors.As(err, &duplicateErr), true)
+}
+
+func TestAgentPool_LinkedcorpPartlyDelivered(t *testing.T) {
+	var sends int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"access_token\":\"token\",\"expires_in\":7200}"))
+		default:
+			atomic.AddInt32(&sends, 1)
+			body, _ := ioutil.ReadAll(r.Body)
+			if strings.Contains(string(body), "quota_user") {
+				_, _ = w.Write([]byte("{\"errcode\":45009,\"errmsg\":\"api freq out of limit\"}"))
+				return
+			}
+			// the linkedcorp response has no msgid
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\"}"))
+		}
+	}))
+	defer ts.Close()
+
+	store := wxcom.NewMemoryDedupStore()
+	pool := wxcom.NewAgentPool(
+		createTestAgent(ts.URL, "secret", 1).SetDeduplicator(store, time.Minute),
+		createTestAgent(ts.URL, "secret", 2).SetDeduplicator(store, time.Minute),
+	)
+
+	users := make([]string, 0, 1001)
+	for i := 0; i < 1000; i++ {
+		users = append(users, fmt.Sprintf("corp1/user%d", i))
+	}
+	users = append(users, "corp1/quota_user")
+	msg := wxcom.New("corp", "secret", 0).Linkedcorp().ToUser(users).IdempotencyKey("alert-1")
+
+	// the first batch has been delivered, so that the send does not fail over to the next agent
+	resp, err := pool.Send(msg.Clone().Text("content"))
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 45009)
+	assertEqual(t, atomic.LoadInt32(&sends), int32(2))
+	assertEqual(t, resp.Result().Failed.Users, []string{"corp1/quota_user"})
+
+	// the key is done
+	_, err = pool.Send(msg.Clone().Text("content"))
+	var duplicateErr *wxcom.DuplicateError
+	assertEqual(t, errors.As(err, &duplicateErr), true)
+	assertEqual(t, atomic.LoadInt32(&sends), int32(2))
+}
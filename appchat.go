@@ -0,0 +1,146 @@
+package wxcom
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// minAppchatUsers is the min count of members when creating the appchat.
+	minAppchatUsers = 2
+	// maxAppchatUsers is the max count of members of the appchat.
+	maxAppchatUsers = 2000
+	// maxAppchatIdLength is the max length of the chatid.
+	maxAppchatIdLength = 32
+	// appchatSendPath is the path of sending message to appchat.
+	appchatSendPath = "/cgi-bin/appchat/send"
+)
+
+// AppchatCreate struct holds the request values of create appchat.
+// The chatid is generated by WeCom when it is empty.
+type AppchatCreate struct {
+	Name     string   `json:"name,omitempty"`
+	Owner    string   `json:"owner,omitempty"`
+	Userlist []string `json:"userlist"`
+	Chatid   string   `json:"chatid,omitempty"`
+}
+
+// AppchatUpdate struct holds the request values of update appchat.
+// The empty fields are not changed.
+type AppchatUpdate struct {
+	Chatid      string   `json:"chatid"`
+	Name        string   `json:"name,omitempty"`
+	Owner       string   `json:"owner,omitempty"`
+	AddUserList []string `json:"add_user_list,omitempty"`
+	DelUserList []string `json:"del_user_list,omitempty"`
+}
+
+// AppchatInfo struct holds the appchat.
+type AppchatInfo struct {
+	Chatid   string   `json:"chatid"`
+	Name     string   `json:"name"`
+	Owner    string   `json:"owner"`
+	Userlist []string `json:"userlist"`
+}
+
+// RespAppchatCreate struct holds response values of create appchat.
+type RespAppchatCreate struct {
+	respCommon
+	Chatid string `json:"chatid"`
+}
+
+// RespAppchatUpdate struct holds response values of update appchat.
+type RespAppchatUpdate struct {
+	respCommon
+}
+
+// RespAppchatGet struct holds response values of get appchat.
+type RespAppchatGet struct {
+	respCommon
+	ChatInfo AppchatInfo `json:"chat_info"`
+}
+
+// Validate method checks the request values of create appchat.
+func (c *AppchatCreate) Validate() error {
+	if len(c.Userlist) < minAppchatUsers || len(c.Userlist) > maxAppchatUsers {
+		return fmt.Errorf("userlist must contain %d to %d users", minAppchatUsers, maxAppchatUsers)
+	}
+	if c.Chatid != "" {
+		return validateChatid(c.Chatid)
+	}
+	return nil
+}
+
+// Validate method checks the request values of update appchat.
+func (u *AppchatUpdate) Validate() error {
+	if u.Chatid == "" {
+		return errors.New("chatid cannot be empty")
+	}
+	if u.Name == "" && u.Owner == "" && len(u.AddUserList) == 0 && len(u.DelUserList) == 0 {
+		return errors.New("name, owner, add_user_list, del_user_list cannot be empty at the same time")
+	}
+	return nil
+}
+
+// CreateAppchat method creates the appchat, the members must be in the visible scope of the agent.
+func (w *Wxcom) CreateAppchat(create *AppchatCreate) (*RespAppchatCreate, error) {
+	response := &RespAppchatCreate{}
+
+	err := create.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.sendWithRetry("/cgi-bin/appchat/create", nil, create, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// UpdateAppchat method renames the appchat, changes the owner, or adds and removes the members.
+func (w *Wxcom) UpdateAppchat(update *AppchatUpdate) (*RespAppchatUpdate, error) {
+	response := &RespAppchatUpdate{}
+
+	err := update.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.sendWithRetry("/cgi-bin/appchat/update", nil, update, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// GetAppchat method get the appchat by chatid.
+func (w *Wxcom) GetAppchat(chatid string) (*RespAppchatGet, error) {
+	response := &RespAppchatGet{}
+
+	if chatid == "" {
+		return nil, errors.New("chatid cannot be empty")
+	}
+
+	err := w.getWithRetry("/cgi-bin/appchat/get", map[string]string{"chatid": chatid}, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// validateChatid method checks the chatid only contains 0-9, a-z and A-Z, and is at most 32 characters.
+func validateChatid(chatid string) error {
+	if len(chatid) > maxAppchatIdLength {
+		return fmt.Errorf("chatid cannot exceed %d characters", maxAppchatIdLength)
+	}
+	for _, c := range chatid {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return errors.New("chatid can only contain 0-9, a-z and A-Z")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package wxcom_test
+
+import (
+	"github.com/mingzaily/go-wxcom"
+	"testing"
+)
+
+func TestWxcom_CreateAppchat(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.CreateAppchat(&wxcom.AppchatCreate{Name: "oncall", Owner: "user1", Userlist: []string{"user1", "user2"}})
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+	assertEqual(t, resp.Chatid, "generated")
+
+	resp, err = tempWx.CreateAppchat(&wxcom.AppchatCreate{Userlist: []string{"user1", "user2"}, Chatid: "chat1"})
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Chatid, "chat1")
+
+	_, err = tempWx.CreateAppchat(&wxcom.AppchatCreate{Userlist: []string{"user1"}})
+	assertEqual(t, err.Error(), "userlist must contain 2 to 2000 users")
+
+	_, err = tempWx.CreateAppchat(&wxcom.AppchatCreate{Userlist: []string{"user1", "user2"}, Chatid: "chat-1"})
+	assertEqual(t, err.Error(), "chatid can only contain 0-9, a-z and A-Z")
+}
+
+func TestWxcom_UpdateAppchat(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.UpdateAppchat(&wxcom.AppchatUpdate{Chatid: "chat1", Name: "new name", AddUserList: []string{"user3"}, DelUserList: []string{"user2"}})
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+
+	_, err = tempWx.UpdateAppchat(&wxcom.AppchatUpdate{Name: "new name"})
+	assertEqual(t, err.Error(), "chatid cannot be empty")
+
+	_, err = tempWx.UpdateAppchat(&wxcom.AppchatUpdate{Chatid: "chat1"})
+	assertNotEqual(t, err, nil)
+}
+
+func TestWxcom_GetAppchat(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.GetAppchat("chat1")
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.ChatInfo, wxcom.AppchatInfo{Chatid: "chat1", Name: "oncall", Owner: "user1", Userlist: []string{"user1", "user2"}})
+
+	_, err = tempWx.GetAppchat("")
+	assertEqual(t, err.Error(), "chatid cannot be empty")
+}
+
+func TestWxcom_Chat(t *testing.T) {
+	chat := wx.Chat("chat1")
+
+	assertJson(t, chat.Clone().Text("content").SetSafe(1).SetEnableIdTrans(1), "{\"chatid\":\"chat1\",\"msgtype\":\"text\",\"safe\":1,\"text\":{\"content\":\"content\"}}")
+	assertJson(t, chat.Clone().Image("media"), "{\"chatid\":\"chat1\",\"image\":{\"media_id\":\"media\"},\"msgtype\":\"image\",\"safe\":0}")
+	assertJson(t, chat.Clone().Markdown("**content**"), "{\"chatid\":\"chat1\",\"markdown\":{\"content\":\"**content**\"},\"msgtype\":\"markdown\"}")
+	assertJson(t, chat.Clone().Textcard("title", "description", "https://test.com"), "{\"chatid\":\"chat1\",\"msgtype\":\"textcard\",\"textcard\":{\"btntxt\":\"\",\"description\":\"description\",\"title\":\"title\",\"url\":\"https://test.com\"}}")
+	assertJson(t, chat.Clone().News([]wxcom.NewsArticle{{Title: "title", Url: "https://test.com"}}), "{\"chatid\":\"chat1\",\"msgtype\":\"news\",\"news\":{\"articles\":[{\"title\":\"title\",\"url\":\"https://test.com\"}]}}")
+
+	err := chat.Clone().ToUser([]string{"user"}).Text("content").Validate()
+	assertEqual(t, err.Error(), "toUser, toParty, toTag must be empty when sending to appchat")
+
+	err = wx.Chat("").ToUser([]string{"user"}).Text("content").Validate()
+	assertEqual(t, err.Error(), "toUser, toParty, toTag must be empty when sending to appchat; chatid cannot be empty")
+
+	err = wx.Chat("chat-1").Text("content").Validate()
+	assertEqual(t, err.Error(), "chatid can only contain 0-9, a-z and A-Z")
+
+	err = chat.Clone().DuplicateCheck(1, 10).Text("content").Validate()
+	assertEqual(t, err.Error(), "duplicate check is not supported by appchat")
+
+	err = chat.Clone().TemplateCard(&wxcom.TemplateCard{CardType: "text_notice"}).Validate()
+	assertEqual(t, err.Error(), "template card is not supported by appchat")
+
+	s, err := wx.M().FromJson("{\"chatid\":\"chat1\",\"msgtype\":\"text\",\"safe\":1,\"text\":{\"content\":\"content\"}}")
+	assertEqual(t, err, nil)
+	assertJson(t, s, "{\"chatid\":\"chat1\",\"msgtype\":\"text\",\"safe\":1,\"text\":{\"content\":\"content\"}}")
+}
+
+func TestWxcom_ChatSend(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.NewChatMessage("chat1").Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+}
@@ -0,0 +1,224 @@
+package wxcom
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// AuditContentFull records the full payload of the message, the image data is recorded by the md5 only.
+	AuditContentFull = 0
+	// AuditContentHash records the content hash only, for privacy.
+	// Without the audit secret the plain SHA-256 is recorded, whose short content can be recovered by trying the candidates.
+	AuditContentHash = 1
+)
+
+// AuditRecord struct holds the audit record of a message send.
+// A send splitting the recipients or content into several requests is recorded once with the merged response.
+type AuditRecord struct {
+	Time            time.Time       `json:"time"`
+	Target          string          `json:"target"`
+	Agentid         int             `json:"agentid,omitempty"`
+	Chatid          string          `json:"chatid,omitempty"`
+	Msgtype         string          `json:"msgtype"`
+	ToUser          []string        `json:"touser,omitempty"`
+	ToParty         []string        `json:"toparty,omitempty"`
+	ToTag           []string        `json:"totag,omitempty"`
+	CorrelationId   string          `json:"correlation_id,omitempty"`
+	Msgids          []string        `json:"msgids,omitempty"`
+	InvalidUsers    []string        `json:"invalid_users,omitempty"`
+	InvalidParties  []string        `json:"invalid_parties,omitempty"`
+	InvalidTags     []string        `json:"invalid_tags,omitempty"`
+	UnlicensedUsers []string        `json:"unlicensed_users,omitempty"`
+	Errcode         int             `json:"errcode"`
+	Errmsg          string          `json:"errmsg,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	Content         json.RawMessage `json:"content,omitempty"`
+	// ContentHash is the hex of the HMAC-SHA256 of the payload keyed by the audit secret.
+	// Without the secret, it is the hex of the plain SHA-256 in the AuditContentHash mode, and empty in the full mode.
+	ContentHash string `json:"content_hash,omitempty"`
+}
+
+// AuditSink interface writes the audit records.
+type AuditSink interface {
+	// Write writes the audit record.
+	Write(record *AuditRecord) error
+}
+
+// SetAuditSink method sets the sink recording every message sent by Message.Send.
+func (w *Wxcom) SetAuditSink(sink AuditSink) *Wxcom {
+	w.audit.sink = sink
+	return w
+}
+
+// SetAuditContent method sets how the content is recorded, AuditContentFull or AuditContentHash.
+func (w *Wxcom) SetAuditContent(mode int) *Wxcom {
+	w.audit.contentMode = mode
+	return w
+}
+
+// SetAuditSecret method sets the secret key of the content hash, so that the short content cannot be
+// recovered from the hash by trying the candidates. Without the secret, the content hash is the plain SHA-256
+// in the AuditContentHash mode, and not recorded in the full mode.
+func (w *Wxcom) SetAuditSecret(secret string) *Wxcom {
+	w.audit.secret = []byte(secret)
+	return w
+}
+
+// OnAuditError method sets the callback of the records failed to write.
+// The send is not failed by the audit sink, since the message has been sent.
+func (w *Wxcom) OnAuditError(fn func(record *AuditRecord, err error)) *Wxcom {
+	w.audit.onError = fn
+	return w
+}
+
+// SetAuditSink method sets the sink recording every message sent by the robot, see Wxcom.SetAuditSink.
+func (r *Robot) SetAuditSink(sink AuditSink) *Robot {
+	r.wx.SetAuditSink(sink)
+	return r
+}
+
+// SetAuditContent method sets how the content is recorded, AuditContentFull or AuditContentHash.
+func (r *Robot) SetAuditContent(mode int) *Robot {
+	r.wx.SetAuditContent(mode)
+	return r
+}
+
+// SetAuditSecret method sets the secret key of the content hash, see Wxcom.SetAuditSecret.
+func (r *Robot) SetAuditSecret(secret string) *Robot {
+	r.wx.SetAuditSecret(secret)
+	return r
+}
+
+// SetAuditSink method sets the sink recording every message sent by the pool, see Wxcom.SetAuditSink.
+func (p *RobotPool) SetAuditSink(sink AuditSink) *RobotPool {
+	p.wx.SetAuditSink(sink)
+	return p
+}
+
+// SetAuditContent method sets how the content is recorded, AuditContentFull or AuditContentHash.
+func (p *RobotPool) SetAuditContent(mode int) *RobotPool {
+	p.wx.SetAuditContent(mode)
+	return p
+}
+
+// SetAuditSecret method sets the secret key of the content hash, see Wxcom.SetAuditSecret.
+func (p *RobotPool) SetAuditSecret(secret string) *RobotPool {
+	p.wx.SetAuditSecret(secret)
+	return p
+}
+
+// auditor struct holds the audit settings of the client.
+type auditor struct {
+	sink        AuditSink
+	contentMode int
+	secret      []byte
+	onError     func(record *AuditRecord, err error)
+}
+
+// audit method writes the audit record of the send.
+func (m *Message) audit(start time.Time, response *RespMessage, sendErr error) {
+	a := m.wx.audit
+	if a.sink == nil {
+		return
+	}
+
+	record := &AuditRecord{
+		Time:          start,
+		Target:        m.target.kind,
+		Chatid:        m.target.chatid,
+		Msgtype:       m.msgType,
+		ToUser:        m.toUser,
+		ToParty:       m.toParty,
+		ToTag:         m.toTag,
+		CorrelationId: m.correlationId,
+	}
+	if m.target.kind != targetRobot && m.target.kind != targetAppchat {
+		record.Agentid = m.wx.agentid
+	}
+
+	if payload, err := m.payload(); err == nil {
+		var body interface{} = payload
+		if m.target.kind == targetLinkedcorp {
+			body = m.linkedcorpPayload(payload)
+		}
+		if content, err := json.Marshal(body); err == nil {
+			if len(a.secret) != 0 {
+				mac := hmac.New(sha256.New, a.secret)
+				mac.Write(content)
+				record.ContentHash = hex.EncodeToString(mac.Sum(nil))
+			} else if a.contentMode == AuditContentHash {
+				sum := sha256.Sum256(content)
+				record.ContentHash = hex.EncodeToString(sum[:])
+			}
+		}
+		if payload.Image != nil && payload.Image.Base64 != "" {
+			// the image data of robot is up to 2MB, the md5 is enough to identify it
+			payload.Image = &MediaPayload{Md5: payload.Image.Md5}
+		}
+		if content, err := json.Marshal(body); err == nil && a.contentMode == AuditContentFull {
+			record.Content = content
+		}
+	}
+
+	if response != nil {
+		result := response.Result()
+		record.Msgids = result.Msgids
+		record.InvalidUsers = result.InvalidUsers
+		record.InvalidParties = result.InvalidParties
+		record.InvalidTags = result.InvalidTags
+		record.UnlicensedUsers = result.UnlicensedUsers
+		record.Errcode = response.Errcode
+		record.Errmsg = response.Errmsg
+	}
+	if sendErr != nil {
+		record.Error = sendErr.Error()
+	}
+
+	if err := a.sink.Write(record); err != nil && a.onError != nil {
+		a.onError(record, err)
+	}
+}
+
+// FileAuditSink struct is the AuditSink appending the records as json lines to a local file.
+type FileAuditSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileAuditSink method opens or creates the audit file.
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileAuditSink{file: f}, nil
+}
+
+// Write method appends the record as a json line.
+func (s *FileAuditSink) Write(record *AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close method closes the audit file.
+func (s *FileAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
@@ -0,0 +1,136 @@
+package wxcom_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/mingzaily/go-wxcom"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+type recordAuditSink struct {
+	mu      sync.Mutex
+	records []*wxcom.AuditRecord
+	err     error
+}
+
+func (s *recordAuditSink) Write(record *wxcom.AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, record)
+	return s.err
+}
+
+func TestWxcom_SetAuditSink(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	sink := &recordAuditSink{}
+	tempWx := wxcom.New("123", "321", 123).SetAuditSink(sink)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	_, err := tempWx.M().ToUser([]string{"user", "invalid_user"}).CorrelationId("deploy").Text("content").Send()
+	assertEqual(t, err, nil)
+
+	_, err = tempWx.M().Text("content").Send()
+	assertNotEqual(t, err, nil)
+
+	assertEqual(t, len(sink.records), 2)
+
+	record := sink.records[0]
+	assertEqual(t, record.Target, "agent")
+	assertEqual(t, record.Agentid, 123)
+	assertEqual(t, record.Msgtype, "text")
+	assertEqual(t, record.ToUser, []string{"user", "invalid_user"})
+	assertEqual(t, record.CorrelationId, "deploy")
+	assertEqual(t, record.Msgids, []string{"msgid"})
+	assertEqual(t, record.InvalidUsers, []string{"invalid_user"})
+	assertEqual(t, record.UnlicensedUsers, []string{"unlicensed_user"})
+	assertEqual(t, string(record.Content), "{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"text\",\"safe\":0,\"text\":{\"content\":\"content\"},\"touser\":\"user|invalid_user\"}")
+	// no content hash without the secret
+	assertEqual(t, record.ContentHash, "")
+
+	assertEqual(t, sink.records[1].Error, "toUser, toParty, toTag cannot be empty at the same time")
+}
+
+func TestWxcom_SetAuditContent(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	var auditErr error
+	sink := &recordAuditSink{err: errors.New("disk full")}
+	tempWx := wxcom.New("123", "321", 123).
+		SetAuditSink(sink).
+		SetAuditContent(wxcom.AuditContentHash).
+		SetAuditSecret("secret").
+		OnAuditError(func(record *wxcom.AuditRecord, err error) {
+			auditErr = err
+		})
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	_, err := tempWx.Chat("chat1").Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, auditErr, sink.err)
+
+	record := sink.records[0]
+	assertEqual(t, record.Target, "appchat")
+	assertEqual(t, record.Chatid, "chat1")
+	assertEqual(t, record.Agentid, 0)
+	assertEqual(t, len(record.Content), 0)
+	assertEqual(t, len(record.ContentHash), 64)
+
+	// the hash is keyed by the secret
+	_, err = tempWx.SetAuditSecret("other").Chat("chat1").Text("content").Send()
+	assertEqual(t, err, nil)
+	assertNotEqual(t, sink.records[1].ContentHash, record.ContentHash)
+
+	// the plain hash without the secret
+	_, err = tempWx.SetAuditSecret("").Chat("chat1").Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, len(sink.records[2].Content), 0)
+	assertEqual(t, len(sink.records[2].ContentHash), 64)
+	assertNotEqual(t, sink.records[2].ContentHash, record.ContentHash)
+}
+
+func TestRobot_SetAuditSink(t *testing.T) {
+	ts := createRobotServer(t)
+	defer ts.Close()
+
+	sink := &recordAuditSink{}
+	robot := wxcom.NewRobot("robot_key").SetAuditSink(sink)
+	robot.Resty.SetBaseURL(ts.URL)
+
+	_, err := robot.M().ImageData([]byte("image")).Send()
+	assertEqual(t, err, nil)
+
+	record := sink.records[0]
+	assertEqual(t, record.Target, "robot")
+	assertEqual(t, string(record.Content), "{\"image\":{\"md5\":\"78805a221a988e79ef3f42d7c5bfd418\"},\"msgtype\":\"image\"}")
+}
+
+func TestNewFileAuditSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := wxcom.NewFileAuditSink(path)
+	assertEqual(t, err, nil)
+	assertEqual(t, sink.Write(&wxcom.AuditRecord{Target: "agent", Msgtype: "text"}), nil)
+	assertEqual(t, sink.Write(&wxcom.AuditRecord{Target: "robot", Msgtype: "markdown"}), nil)
+	assertEqual(t, sink.Close(), nil)
+
+	f, err := os.Open(path)
+	assertEqual(t, err, nil)
+	defer f.Close()
+
+	var targets []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		record := &wxcom.AuditRecord{}
+		assertEqual(t, json.Unmarshal(scanner.Bytes(), record), nil)
+		targets = append(targets, record.Target)
+	}
+	assertEqual(t, targets, []string{"agent", "robot"})
+}
@@ -0,0 +1,271 @@
+package wxcom
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DigestMarkdown sends the digest as markdown message.
+	DigestMarkdown = "markdown"
+	// DigestNews sends the digest as news message, one article for each entry.
+	DigestNews = "news"
+)
+
+// CoalescedEntry struct holds a notification added to the coalescer.
+type CoalescedEntry struct {
+	Title   string
+	Content string
+	Url     string
+	Time    time.Time
+}
+
+// Coalescer struct is used to group the notifications to the same recipients and category
+// within a time window, and send them as a single digest message.
+type Coalescer struct {
+	window     time.Duration
+	maxBatch   int
+	digestKind string
+	digestUrl  string
+	digest     func(m *Message, category string, entries []CoalescedEntry) Sendable
+	onError    func(category string, entries []CoalescedEntry, err error)
+
+	mu      sync.Mutex
+	groups  map[string]*coalesceGroup
+	closed  bool
+	sending sync.WaitGroup
+}
+
+// coalesceGroup struct holds the entries of the same key within the window.
+type coalesceGroup struct {
+	message  *Message
+	category string
+	entries  []CoalescedEntry
+	timer    *time.Timer
+}
+
+// NewCoalescer method creates a new Coalescer instance with the time window.
+// The window starts when the first notification of the key is added.
+func (w *Wxcom) NewCoalescer(window time.Duration) *Coalescer {
+	return &Coalescer{
+		window:     window,
+		maxBatch:   50,
+		digestKind: DigestMarkdown,
+		groups:     make(map[string]*coalesceGroup),
+	}
+}
+
+// SetMaxBatch method sets the max entries of a digest, the digest is sent at once when reached.
+func (c *Coalescer) SetMaxBatch(maxBatch int) *Coalescer {
+	c.maxBatch = maxBatch
+	return c
+}
+
+// SetDigestKind method sets the digest message kind, DigestMarkdown or DigestNews.
+// The url is used by the news articles of the entries without url,
+// the news digest falls back to markdown if some article has no url.
+func (c *Coalescer) SetDigestKind(kind, url string) *Coalescer {
+	c.digestKind = kind
+	c.digestUrl = url
+	return c
+}
+
+// SetDigest method sets the function to compose the digest message instead of the built-in ones.
+func (c *Coalescer) SetDigest(fn func(m *Message, category string, entries []CoalescedEntry) Sendable) *Coalescer {
+	c.digest = fn
+	return c
+}
+
+// OnError method sets the callback of the failed digests.
+func (c *Coalescer) OnError(fn func(category string, entries []CoalescedEntry, err error)) *Coalescer {
+	c.onError = fn
+	return c
+}
+
+// Add method adds the notification to the recipients of the message under the category,
+// the notifications sent by other agents or to linkedcorp are grouped separately.
+// The options of the message, such as DuplicateCheck, are used by the digest.
+func (c *Coalescer) Add(m *Message, category string, entry CoalescedEntry) error {
+	if m.recipients().Empty() {
+		return errors.New("toUser, toParty, toTag cannot be empty at the same time")
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	key := coalesceKey(m.target.sender(m.wx), m.recipients(), category)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errors.New("coalescer is closed")
+	}
+
+	group, found := c.groups[key]
+	if !found {
+		group = &coalesceGroup{message: m.clone(), category: category}
+		group.timer = time.AfterFunc(c.window, func() {
+			c.flushKey(key, group)
+		})
+		c.groups[key] = group
+	}
+	group.entries = append(group.entries, entry)
+
+	if c.maxBatch > 0 && len(group.entries) >= c.maxBatch {
+		group.timer.Stop()
+		delete(c.groups, key)
+		c.sending.Add(1)
+		go func() {
+			defer c.sending.Done()
+			c.send(group)
+		}()
+	}
+
+	return nil
+}
+
+// Flush method sends the digests of all the groups at once.
+func (c *Coalescer) Flush() {
+	c.mu.Lock()
+	groups := c.groups
+	c.groups = make(map[string]*coalesceGroup)
+	c.mu.Unlock()
+
+	for _, group := range groups {
+		group.timer.Stop()
+		c.send(group)
+	}
+}
+
+// Close method stops accepting notifications, sends the pending digests and waits for the digests being sent.
+func (c *Coalescer) Close() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+
+	c.Flush()
+	c.sending.Wait()
+}
+
+// flushKey method sends the digest of the group when the window ends.
+func (c *Coalescer) flushKey(key string, group *coalesceGroup) {
+	c.mu.Lock()
+	if c.groups[key] != group {
+		// already sent
+		c.mu.Unlock()
+		return
+	}
+	delete(c.groups, key)
+	c.sending.Add(1)
+	c.mu.Unlock()
+
+	defer c.sending.Done()
+	c.send(group)
+}
+
+// send method sends the digest of the group.
+func (c *Coalescer) send(group *coalesceGroup) {
+	var s Sendable
+	switch {
+	case c.digest != nil:
+		s = c.digest(group.message, group.category, group.entries)
+	case c.digestKind == DigestNews:
+		s = newsDigest(group.message, group.category, group.entries, c.digestUrl)
+	default:
+		s = markdownDigest(group.message, group.category, group.entries)
+	}
+
+	resp, err := s.Send()
+	if err == nil {
+		err = resp.err()
+	}
+	if err != nil && c.onError != nil {
+		c.onError(group.category, group.entries, err)
+	}
+}
+
+// coalesceKey method returns the key of the sender, recipients and category, the order of the recipients does not matter.
+func coalesceKey(sender string, recipients Recipients, category string) string {
+	sorted := func(list []string) string {
+		copied := append([]string(nil), list...)
+		sort.Strings(copied)
+		return strings.Join(copied, "|")
+	}
+	return strings.Join([]string{sender, sorted(recipients.Users), sorted(recipients.Parties), sorted(recipients.Tags), category}, "\n")
+}
+
+// markdownDigest method composes the markdown digest, the entries exceeding the limit are counted at the end.
+func markdownDigest(m *Message, category string, entries []CoalescedEntry) Sendable {
+	b := NewMarkdownBuilder().SetLimit(maxMarkdownContentBytes)
+	if len(entries) == 1 {
+		b.Bold(category).Line("")
+	} else {
+		b.Bold(category).Text(" ").Comment(fmt.Sprintf("%d notifications", len(entries))).Line("")
+	}
+
+	for i, entry := range entries {
+		line := NewMarkdownBuilder().SetLimit(maxMarkdownContentBytes).
+			Raw("\n").Comment(entry.Time.Format("15:04:05")).Text(" ")
+		if entry.Url != "" {
+			line.Link(entry.Title, entry.Url)
+		} else {
+			line.Bold(entry.Title)
+		}
+		if entry.Content != "" {
+			line.Raw("\n").Text(entry.Content)
+		}
+
+		more := fmt.Sprintf("\n\n... and %d more", len(entries)-i)
+		if line.Err() != nil || b.Remaining() < line.Len()+len(more) {
+			b.Raw(more)
+			break
+		}
+		b.Raw(line.String() + "\n")
+	}
+
+	return m.Markdown(strings.TrimSpace(b.String()))
+}
+
+// newsDigest method composes the news digest, the entries exceeding the max articles are counted in the last article.
+// The markdown digest is composed instead if some article has no url.
+func newsDigest(m *Message, category string, entries []CoalescedEntry, url string) Sendable {
+	if url == "" {
+		if len(entries) > maxNewsArticles {
+			return markdownDigest(m, category, entries)
+		}
+		for _, entry := range entries {
+			if entry.Url == "" {
+				return markdownDigest(m, category, entries)
+			}
+		}
+	}
+
+	var articles []NewsArticle
+	for i, entry := range entries {
+		if len(entries) > maxNewsArticles && i == maxNewsArticles-1 {
+			articles = append(articles, NewsArticle{
+				Title:       truncateBytes(fmt.Sprintf("%s: %d more notifications", category, len(entries)-i), maxTitleBytes),
+				Description: truncateBytes(entry.Title, maxDescriptionBytes),
+				Url:         url,
+			})
+			break
+		}
+
+		articleUrl := entry.Url
+		if articleUrl == "" {
+			articleUrl = url
+		}
+		articles = append(articles, NewsArticle{
+			Title:       truncateBytes(entry.Title, maxTitleBytes),
+			Description: truncateBytes(entry.Content, maxDescriptionBytes),
+			Url:         articleUrl,
+		})
+	}
+
+	return m.News(articles)
+}
@@ -0,0 +1,148 @@
+package wxcom_test
+
+import (
+	"encoding/json"
+	"github.com/mingzaily/go-wxcom"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// createRecordServer creates a test server recording the payloads of the sent messages.
+func createRecordServer(t *testing.T) (*httptest.Server, func() []wxcom.MessagePayload) {
+	var mu sync.Mutex
+	var payloads []wxcom.MessagePayload
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"access_token\":\"token\",\"expires_in\":7200}"))
+		default:
+			body, _ := ioutil.ReadAll(r.Body)
+			payload := wxcom.MessagePayload{}
+			if err := json.Unmarshal(body, &payload); err != nil {
+				t.Errorf("Unexpected body [%s]", body)
+			}
+			mu.Lock()
+			payloads = append(payloads, payload)
+			mu.Unlock()
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"msgid\":\"msgid\"}"))
+		}
+	}))
+
+	return ts, func() []wxcom.MessagePayload {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]wxcom.MessagePayload(nil), payloads...)
+	}
+}
+
+func TestCoalescer_Window(t *testing.T) {
+	ts, payloads := createRecordServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	coalescer := tempWx.NewCoalescer(50 * time.Millisecond)
+	defer coalescer.Close()
+
+	for i := 0; i < 3; i++ {
+		err := coalescer.Add(tempWx.M().ToUser([]string{"a", "b"}), "CPU", wxcom.CoalescedEntry{Title: "api-01 high", Content: "95%"})
+		assertEqual(t, err, nil)
+	}
+	// the same recipients in other order
+	assertEqual(t, coalescer.Add(tempWx.M().ToUser([]string{"b", "a"}), "CPU", wxcom.CoalescedEntry{Title: "api-02 high"}), nil)
+	// other category
+	assertEqual(t, coalescer.Add(tempWx.M().ToUser([]string{"a", "b"}), "Disk", wxcom.CoalescedEntry{Title: "db-01 full"}), nil)
+
+	assertEqual(t, len(payloads()), 0)
+	time.Sleep(200 * time.Millisecond)
+
+	sent := payloads()
+	assertEqual(t, len(sent), 2)
+	for _, payload := range sent {
+		assertEqual(t, payload.Msgtype, "markdown")
+		if strings.HasPrefix(payload.Markdown.Content, "**CPU**") {
+			assertEqual(t, strings.Contains(payload.Markdown.Content, "4 notifications"), true)
+			assertEqual(t, strings.Count(payload.Markdown.Content, "api-0"), 4)
+		} else {
+			assertEqual(t, strings.HasPrefix(payload.Markdown.Content, "**Disk**\n"), true)
+		}
+	}
+}
+
+func TestCoalescer_Sender(t *testing.T) {
+	ts, payloads := createRecordServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+	otherWx := wxcom.New("123", "321", 456)
+	otherWx.Resty.SetBaseURL(ts.URL)
+
+	coalescer := tempWx.NewCoalescer(time.Minute)
+	defer coalescer.Close()
+
+	// the same recipients and category of other agents are not grouped
+	assertEqual(t, coalescer.Add(tempWx.M().ToUser([]string{"a"}), "CPU", wxcom.CoalescedEntry{Title: "api-01 high"}), nil)
+	assertEqual(t, coalescer.Add(otherWx.M().ToUser([]string{"a"}), "CPU", wxcom.CoalescedEntry{Title: "api-02 high"}), nil)
+	coalescer.Flush()
+
+	sent := payloads()
+	assertEqual(t, len(sent), 2)
+	assertEqual(t, sent[0].Agentid+sent[1].Agentid, 123+456)
+}
+
+func TestCoalescer_MaxBatch(t *testing.T) {
+	ts, payloads := createRecordServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	coalescer := tempWx.NewCoalescer(time.Hour).SetMaxBatch(10).SetDigestKind(wxcom.DigestNews, "https://alert.test")
+
+	for i := 0; i < 10; i++ {
+		assertEqual(t, coalescer.Add(tempWx.M().ToUser([]string{"a"}), "CPU", wxcom.CoalescedEntry{Title: "high"}), nil)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	sent := payloads()
+	assertEqual(t, len(sent), 1)
+	assertEqual(t, len(sent[0].News.Articles), 8)
+	assertEqual(t, sent[0].News.Articles[7].Title, "CPU: 3 more notifications")
+	assertEqual(t, sent[0].News.Articles[0].Url, "https://alert.test")
+
+	assertEqual(t, coalescer.Add(tempWx.M().ToUser([]string{"a"}), "CPU", wxcom.CoalescedEntry{Title: "high"}), nil)
+	coalescer.Close()
+	assertEqual(t, len(payloads()), 2)
+
+	err := coalescer.Add(tempWx.M().ToUser([]string{"a"}), "CPU", wxcom.CoalescedEntry{Title: "high"})
+	assertEqual(t, err.Error(), "coalescer is closed")
+}
+
+func TestCoalescer_CloseWaitsForSending(t *testing.T) {
+	ts, payloads := createRecordServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	coalescer := tempWx.NewCoalescer(time.Hour).SetMaxBatch(2).SetDigestKind(wxcom.DigestNews, "")
+
+	for i := 0; i < 2; i++ {
+		assertEqual(t, coalescer.Add(tempWx.M().ToUser([]string{"a"}), "CPU", wxcom.CoalescedEntry{Title: "high"}), nil)
+	}
+	coalescer.Close()
+
+	// the news digest without url falls back to markdown
+	sent := payloads()
+	assertEqual(t, len(sent), 1)
+	assertEqual(t, sent[0].Msgtype, "markdown")
+}
@@ -0,0 +1,490 @@
+package wxcom
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CommonMark is the document parsed from standard markdown,
+// which can be rendered into WeCom markdown, plain text or textcard.
+//
+// The constructs not supported by WeCom are degraded:
+// lists are rendered as bullet lines, tables as "header: value" lines,
+// code blocks as inline code lines, images as links, and italic, strikethrough and html tags are dropped.
+// The line breaks inside paragraphs are kept, since they are usually meaningful in notifications.
+type CommonMark struct {
+	blocks []cmBlock
+}
+
+// cmBlockKind is the kind of the block.
+type cmBlockKind int
+
+const (
+	cmParagraph cmBlockKind = iota
+	cmHeading
+	cmQuote
+	cmList
+	cmCode
+	cmTable
+	cmBreak
+)
+
+// cmBlock struct holds a block of the document.
+type cmBlock struct {
+	kind  cmBlockKind
+	level int
+	lines []string
+	items []cmListItem
+	rows  [][]string
+}
+
+// cmListItem struct holds a list item.
+type cmListItem struct {
+	depth  int
+	marker string
+	text   string
+}
+
+var (
+	cmFenceRe      = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	cmHeadingRe    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?[ \t#]*$`)
+	cmBreakRe      = regexp.MustCompile(`^ {0,3}((\*[ \t]*){3,}|(-[ \t]*){3,}|(_[ \t]*){3,})$`)
+	cmQuoteRe      = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+	cmListRe       = regexp.MustCompile(`^(\s*)([-*+]|\d{1,9}[.)])[ \t]+(.*)$`)
+	cmTableSepRe   = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	cmSetextRe     = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	cmHtmlTagRe    = regexp.MustCompile(`^</?([a-zA-Z][a-zA-Z0-9]*)\b[^>]*>`)
+	cmAutolinkRe   = regexp.MustCompile(`^<(https?://[^>\s]+)>`)
+	cmLinkTailRe   = regexp.MustCompile(`^\(\s*<?([^)\s>]*)>?(?:\s+"[^"]*")?\s*\)`)
+	cmIndentCodeRe = regexp.MustCompile(`^( {4}|\t)(.*)$`)
+)
+
+// ParseCommonMark method parses the standard markdown.
+func ParseCommonMark(src string) *CommonMark {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	doc := &CommonMark{}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case cmFenceRe.MatchString(line):
+			fence := cmFenceRe.FindStringSubmatch(line)[1]
+			block := cmBlock{kind: cmCode}
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence[:3]); i++ {
+				block.lines = append(block.lines, lines[i])
+			}
+			i++
+			doc.blocks = append(doc.blocks, block)
+		case cmHeadingRe.MatchString(line):
+			match := cmHeadingRe.FindStringSubmatch(line)
+			doc.blocks = append(doc.blocks, cmBlock{kind: cmHeading, level: len(match[1]), lines: []string{match[2]}})
+			i++
+		case cmBreakRe.MatchString(line):
+			doc.blocks = append(doc.blocks, cmBlock{kind: cmBreak})
+			i++
+		case cmQuoteRe.MatchString(line):
+			block := cmBlock{kind: cmQuote}
+			for ; i < len(lines) && cmQuoteRe.MatchString(lines[i]); i++ {
+				block.lines = append(block.lines, cmQuoteRe.FindStringSubmatch(lines[i])[1])
+			}
+			doc.blocks = append(doc.blocks, block)
+		case cmListRe.MatchString(line):
+			block := cmBlock{kind: cmList}
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+				match := cmListRe.FindStringSubmatch(lines[i])
+				if match == nil {
+					// lazy continuation line of the last item
+					last := &block.items[len(block.items)-1]
+					last.text += "\n" + strings.TrimSpace(lines[i])
+					continue
+				}
+				indent := strings.ReplaceAll(match[1], "\t", "    ")
+				block.items = append(block.items, cmListItem{depth: len(indent) / 2, marker: match[2], text: match[3]})
+			}
+			doc.blocks = append(doc.blocks, block)
+		case strings.Contains(line, "|") && i+1 < len(lines) && cmTableSepRe.MatchString(lines[i+1]) &&
+			strings.Contains(lines[i+1], "-"):
+			block := cmBlock{kind: cmTable, rows: [][]string{splitTableRow(line)}}
+			for i += 2; i < len(lines) && strings.Contains(lines[i], "|"); i++ {
+				block.rows = append(block.rows, splitTableRow(lines[i]))
+			}
+			doc.blocks = append(doc.blocks, block)
+		case cmIndentCodeRe.MatchString(line):
+			block := cmBlock{kind: cmCode}
+			for ; i < len(lines) && (cmIndentCodeRe.MatchString(lines[i]) || strings.TrimSpace(lines[i]) == ""); i++ {
+				block.lines = append(block.lines, cmIndentCodeRe.ReplaceAllString(lines[i], "$2"))
+			}
+			doc.blocks = append(doc.blocks, block)
+		default:
+			block := cmBlock{kind: cmParagraph}
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+				if len(block.lines) != 0 && cmSetextRe.MatchString(lines[i]) {
+					level := 2
+					if strings.Contains(lines[i], "=") {
+						level = 1
+					}
+					block = cmBlock{kind: cmHeading, level: level, lines: []string{strings.Join(block.lines, " ")}}
+					i++
+					break
+				}
+				if len(block.lines) != 0 && startsBlock(lines[i]) {
+					break
+				}
+				block.lines = append(block.lines, strings.TrimSpace(lines[i]))
+			}
+			doc.blocks = append(doc.blocks, block)
+		}
+	}
+
+	return doc
+}
+
+// startsBlock method reports whether the line interrupts a paragraph.
+func startsBlock(line string) bool {
+	return cmFenceRe.MatchString(line) || cmHeadingRe.MatchString(line) || cmBreakRe.MatchString(line) ||
+		cmQuoteRe.MatchString(line) || cmListRe.MatchString(line)
+}
+
+// splitTableRow method splits the cells of the table row.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+
+	cells := strings.Split(line, "|")
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(cell)
+	}
+	return cells
+}
+
+// Markdown method renders the document into WeCom markdown.
+func (d *CommonMark) Markdown() string {
+	return d.render(true)
+}
+
+// Text method renders the document into plain text.
+func (d *CommonMark) Text() string {
+	return d.render(false)
+}
+
+// Title method returns the plain text of the first heading, or the first line if there is no heading.
+func (d *CommonMark) Title() string {
+	for _, block := range d.blocks {
+		if block.kind == cmHeading {
+			return renderInline(block.lines[0], false)
+		}
+	}
+	for _, block := range d.blocks {
+		if block.kind != cmBreak {
+			return strings.SplitN(d.renderBlock(block, false), "\n", 2)[0]
+		}
+	}
+	return ""
+}
+
+// FirstLink method returns the url of the first link, empty if there is no link.
+func (d *CommonMark) FirstLink() string {
+	for _, block := range d.blocks {
+		texts := append([]string(nil), block.lines...)
+		for _, item := range block.items {
+			texts = append(texts, item.text)
+		}
+		for _, row := range block.rows {
+			texts = append(texts, row...)
+		}
+		if block.kind == cmCode {
+			continue
+		}
+		for _, text := range texts {
+			if url := firstInlineLink(text); url != "" {
+				return url
+			}
+		}
+	}
+	return ""
+}
+
+// Textcard method creates textcard message from the document.
+// The title is the first heading, the description is the plain text of the rest,
+// and the url is the first link if the given url is empty.
+// The title and description are truncated to the WeCom limits.
+func (d *CommonMark) Textcard(m *Message, url string) *textcard {
+	title := d.Title()
+
+	// the block where the title comes from is not repeated in the description
+	rest := &CommonMark{}
+	titled := false
+	for _, block := range d.blocks {
+		if !titled && (block.kind == cmHeading || !hasHeading(d.blocks)) && block.kind != cmBreak {
+			titled = true
+			if block.kind == cmParagraph && len(block.lines) > 1 {
+				block.lines = block.lines[1:]
+				rest.blocks = append(rest.blocks, block)
+			}
+			if block.kind == cmHeading || block.kind == cmParagraph {
+				continue
+			}
+		}
+		rest.blocks = append(rest.blocks, block)
+	}
+
+	if url == "" {
+		url = d.FirstLink()
+	}
+
+	return m.Textcard(truncateBytes(title, maxTitleBytes), truncateBytes(rest.Text(), maxDescriptionBytes), url)
+}
+
+// hasHeading method reports whether there is a heading in the blocks.
+func hasHeading(blocks []cmBlock) bool {
+	for _, block := range blocks {
+		if block.kind == cmHeading {
+			return true
+		}
+	}
+	return false
+}
+
+// render method renders all the blocks.
+func (d *CommonMark) render(markdown bool) string {
+	var parts []string
+	for _, block := range d.blocks {
+		if rendered := d.renderBlock(block, markdown); rendered != "" || block.kind == cmBreak {
+			parts = append(parts, rendered)
+		}
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n\n"))
+}
+
+// renderBlock method renders a block.
+func (d *CommonMark) renderBlock(block cmBlock, markdown bool) string {
+	var lines []string
+
+	switch block.kind {
+	case cmParagraph:
+		for _, line := range block.lines {
+			lines = append(lines, renderInline(line, markdown))
+		}
+	case cmHeading:
+		text := renderInline(block.lines[0], markdown)
+		if markdown {
+			text = strings.Repeat("#", block.level) + " " + text
+		}
+		lines = append(lines, text)
+	case cmQuote:
+		for _, line := range block.lines {
+			text := renderInline(line, markdown)
+			if markdown {
+				text = "> " + text
+			}
+			lines = append(lines, text)
+		}
+	case cmList:
+		number := 0
+		for _, item := range block.items {
+			marker := "• "
+			if unicode.IsDigit(rune(item.marker[0])) {
+				n, _ := strconv.Atoi(strings.TrimRight(item.marker, ".)"))
+				if number == 0 || item.depth > 0 {
+					number = n
+				} else {
+					number++
+				}
+				marker = strconv.Itoa(number) + ". "
+			}
+			indent := strings.Repeat("  ", item.depth)
+			for j, text := range strings.Split(item.text, "\n") {
+				if j > 0 {
+					marker = strings.Repeat(" ", utf8.RuneCountInString(marker))
+				}
+				lines = append(lines, indent+marker+renderInline(text, markdown))
+			}
+		}
+	case cmCode:
+		for _, line := range block.lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if markdown {
+				line = NewMarkdownBuilder().SetLimit(len(line)*2 + 8).Code(line).String()
+			}
+			lines = append(lines, line)
+		}
+	case cmTable:
+		header := block.rows[0]
+		for _, row := range block.rows[1:] {
+			var cells []string
+			for j, cell := range row {
+				value := renderInline(cell, markdown)
+				if j < len(header) && header[j] != "" {
+					name := renderInline(header[j], markdown)
+					if markdown {
+						name = "**" + name + "**"
+					}
+					value = name + ": " + value
+				}
+				cells = append(cells, value)
+			}
+			lines = append(lines, strings.Join(cells, ", "))
+		}
+		if len(block.rows) == 1 {
+			lines = append(lines, renderInline(strings.Join(header, ", "), markdown))
+		}
+	case cmBreak:
+		if !markdown {
+			lines = append(lines, "----------")
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// renderInline method renders the inline content.
+// The markup supported by WeCom is kept when markdown is true, otherwise all the markup is removed.
+func renderInline(s string, markdown bool) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(s); {
+		rest := s[i:]
+
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.ContainsRune("\\`*_{}[]()#+-.!|<>~", rune(rest[1])):
+			sb.WriteString(literal(rest[1:2], markdown))
+			i += 2
+			continue
+		case rest[0] == '`':
+			run := len(rest) - len(strings.TrimLeft(rest, "`"))
+			if end := strings.Index(rest[run:], rest[:run]); end >= 0 {
+				code := strings.TrimSpace(rest[run : run+end])
+				if markdown {
+					code = NewMarkdownBuilder().SetLimit(len(code)*2 + 8).Code(code).String()
+				}
+				sb.WriteString(code)
+				i += run + end + run
+				continue
+			}
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if end := strings.Index(rest[2:], rest[:2]); end > 0 {
+				inner := renderInline(rest[2:2+end], markdown)
+				if markdown {
+					inner = "**" + inner + "**"
+				}
+				sb.WriteString(inner)
+				i += 2 + end + 2
+				continue
+			}
+		case strings.HasPrefix(rest, "~~"):
+			if end := strings.Index(rest[2:], "~~"); end > 0 {
+				sb.WriteString(renderInline(rest[2:2+end], markdown))
+				i += 2 + end + 2
+				continue
+			}
+		case rest[0] == '*' || (rest[0] == '_' && (i == 0 || !isWordByte(s[i-1]))):
+			if end := strings.IndexByte(rest[1:], rest[0]); end > 0 && rest[1] != ' ' {
+				sb.WriteString(renderInline(rest[1:1+end], markdown))
+				i += 1 + end + 1
+				continue
+			}
+		case strings.HasPrefix(rest, "![") || rest[0] == '[':
+			start := 1
+			if rest[0] == '!' {
+				start = 2
+			}
+			if end := strings.Index(rest[start:], "]"); end >= 0 {
+				if tail := cmLinkTailRe.FindStringSubmatch(rest[start+end+1:]); tail != nil {
+					text := renderInline(rest[start:start+end], markdown)
+					if text == "" {
+						text = tail[1]
+					}
+					sb.WriteString(renderLink(text, tail[1], markdown))
+					i += start + end + 1 + len(tail[0])
+					continue
+				}
+			}
+		case rest[0] == '<':
+			if match := cmAutolinkRe.FindStringSubmatch(rest); match != nil {
+				sb.WriteString(renderLink(match[1], match[1], markdown))
+				i += len(match[0])
+				continue
+			}
+			if match := cmHtmlTagRe.FindStringSubmatch(rest); match != nil {
+				if markdown && strings.EqualFold(match[1], "font") {
+					sb.WriteString(match[0])
+				}
+				i += len(match[0])
+				continue
+			}
+		}
+
+		_, size := utf8.DecodeRuneInString(rest)
+		sb.WriteString(literal(rest[:size], markdown))
+		i += size
+	}
+
+	return sb.String()
+}
+
+// renderLink method renders the link.
+func renderLink(text, url string, markdown bool) string {
+	if markdown {
+		return "[" + text + "](" + url + ")"
+	}
+	if text == url {
+		return url
+	}
+	return text + " (" + url + ")"
+}
+
+// literal method renders the literal text, the markup characters are escaped in markdown.
+func literal(s string, markdown bool) string {
+	if markdown {
+		return markdownEscaper.Replace(s)
+	}
+	return s
+}
+
+// firstInlineLink method returns the url of the first link in the inline content.
+func firstInlineLink(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '<' {
+			if match := cmAutolinkRe.FindStringSubmatch(s[i:]); match != nil {
+				return match[1]
+			}
+		}
+		if s[i] == ']' {
+			if tail := cmLinkTailRe.FindStringSubmatch(s[i+1:]); tail != nil && strings.Contains(s[:i], "[") {
+				return tail[1]
+			}
+		}
+	}
+	return ""
+}
+
+// isWordByte method reports whether the byte is a letter, digit or underscore.
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+}
+
+// truncateBytes method truncates the string to at most max bytes on the rune boundary, with an ellipsis.
+func truncateBytes(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+
+	const ellipsis = "…"
+	end := max - len(ellipsis)
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	if end < 0 {
+		return ""
+	}
+	return s[:end] + ellipsis
+}
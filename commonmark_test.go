@@ -0,0 +1,80 @@
+package wxcom_test
+
+import (
+	"github.com/mingzaily/go-wxcom"
+	"strings"
+	"testing"
+)
+
+const commonMarkSrc = `# CPU *high* on api-01
+
+Load is **95%** since 10:00, see [dashboard](https://grafana.test/d/1).
+
+- check ` + "`top`" + `
+- restart service
+  1. drain
+  2. restart
+
+| host | cpu |
+| --- | --- |
+| api-01 | 95% |
+| api-02 | 40% |
+
+` + "```" + `
+kill -9 1234
+` + "```" + `
+
+> escalate if not fixed in ~~10~~ 5 min
+
+---
+![graph](https://grafana.test/g.png) <b>done</b>`
+
+func TestCommonMark_Markdown(t *testing.T) {
+	doc := wxcom.ParseCommonMark(commonMarkSrc)
+
+	assertEqual(t, doc.Markdown(), strings.Join([]string{
+		"# CPU high on api-01",
+		"Load is **95%** since 10:00, see [dashboard](https://grafana.test/d/1).",
+		"• check `top`\n• restart service\n  1. drain\n  2. restart",
+		"**host**: api-01, **cpu**: 95%\n**host**: api-02, **cpu**: 40%",
+		"`kill -9 1234`",
+		"> escalate if not fixed in 10 5 min",
+		"",
+		"[graph](https://grafana.test/g.png) done",
+	}, "\n\n"))
+}
+
+func TestCommonMark_Text(t *testing.T) {
+	doc := wxcom.ParseCommonMark(commonMarkSrc)
+
+	assertEqual(t, doc.Text(), strings.Join([]string{
+		"CPU high on api-01",
+		"Load is 95% since 10:00, see dashboard (https://grafana.test/d/1).",
+		"• check top\n• restart service\n  1. drain\n  2. restart",
+		"host: api-01, cpu: 95%\nhost: api-02, cpu: 40%",
+		"kill -9 1234",
+		"escalate if not fixed in 10 5 min",
+		"----------",
+		"graph (https://grafana.test/g.png) done",
+	}, "\n\n"))
+}
+
+func TestCommonMark_Escape(t *testing.T) {
+	doc := wxcom.ParseCommonMark("Title\n===\nuse snake_case and 3 * 4 <font color=\"info\">ok</font>")
+
+	assertEqual(t, doc.Markdown(), "# Title\n\nuse snake\\_case and 3 \\* 4 <font color=\"info\">ok</font>")
+	assertEqual(t, doc.Text(), "Title\n\nuse snake_case and 3 * 4 ok")
+}
+
+func TestCommonMark_Textcard(t *testing.T) {
+	doc := wxcom.ParseCommonMark(commonMarkSrc)
+
+	assertEqual(t, doc.Title(), "CPU high on api-01")
+	assertEqual(t, doc.FirstLink(), "https://grafana.test/d/1")
+
+	m := doc.Textcard(msg.Clone().ToUser([]string{"test"}), "")
+	assertEqual(t, m.Validate(), nil)
+
+	m = wxcom.ParseCommonMark("first line\n"+strings.Repeat("很长", 200)).Textcard(msg.Clone().ToUser([]string{"test"}), "https://test.com")
+	assertEqual(t, m.Validate(), nil)
+}
@@ -0,0 +1,217 @@
+package wxcom
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// contactCacheExpiration is how long the agent scope, departments and users are cached.
+const contactCacheExpiration = 5 * time.Minute
+
+// RespAgent struct holds response values of get agent.
+type RespAgent struct {
+	respCommon
+	Agentid        int    `json:"agentid"`
+	Name           string `json:"name"`
+	SquareLogoUrl  string `json:"square_logo_url"`
+	Description    string `json:"description"`
+	AllowUserinfos struct {
+		User []struct {
+			Userid string `json:"userid"`
+		} `json:"user"`
+	} `json:"allow_userinfos"`
+	AllowPartys struct {
+		Partyid []int `json:"partyid"`
+	} `json:"allow_partys"`
+	AllowTags struct {
+		Tagid []int `json:"tagid"`
+	} `json:"allow_tags"`
+	Close              int    `json:"close"`
+	RedirectDomain     string `json:"redirect_domain"`
+	ReportLocationFlag int    `json:"report_location_flag"`
+	Isreportenter      int    `json:"isreportenter"`
+	HomeUrl            string `json:"home_url"`
+}
+
+// Department struct holds a department.
+type Department struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	NameEn   string `json:"name_en"`
+	Parentid int    `json:"parentid"`
+	Order    int    `json:"order"`
+}
+
+// RespDepartmentList struct holds response values of list departments.
+type RespDepartmentList struct {
+	respCommon
+	Department []Department `json:"department"`
+}
+
+// RespUser struct holds response values of get user.
+type RespUser struct {
+	respCommon
+	Userid     string `json:"userid"`
+	Name       string `json:"name"`
+	Department []int  `json:"department"`
+	Mobile     string `json:"mobile"`
+	Email      string `json:"email"`
+	Status     int    `json:"status"`
+}
+
+// RespTag struct holds response values of get tag members.
+type RespTag struct {
+	respCommon
+	Tagname  string `json:"tagname"`
+	Userlist []struct {
+		Userid string `json:"userid"`
+		Name   string `json:"name"`
+	} `json:"userlist"`
+	Partylist []int `json:"partylist"`
+}
+
+// GetAgent method get the agent of the client, including its visible scope.
+func (w *Wxcom) GetAgent() (*RespAgent, error) {
+	response := &RespAgent{}
+
+	err := w.getWithRetry("/cgi-bin/agent/get", map[string]string{"agentid": strconv.Itoa(w.agentid)}, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ListDepartments method list the department and its sub departments, all the departments if id is 0.
+func (w *Wxcom) ListDepartments(id int) (*RespDepartmentList, error) {
+	response := &RespDepartmentList{}
+
+	var query map[string]string
+	if id != 0 {
+		query = map[string]string{"id": strconv.Itoa(id)}
+	}
+
+	err := w.getWithRetry("/cgi-bin/department/list", query, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// GetUser method get the user by userid.
+func (w *Wxcom) GetUser(userid string) (*RespUser, error) {
+	response := &RespUser{}
+
+	err := w.getWithRetry("/cgi-bin/user/get", map[string]string{"userid": userid}, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// GetTag method get the members of the tag.
+func (w *Wxcom) GetTag(tagid int) (*RespTag, error) {
+	response := &RespTag{}
+
+	err := w.getWithRetry("/cgi-bin/tag/get", map[string]string{"tagid": strconv.Itoa(tagid)}, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// cachedAgent method get the agent from cache or server.
+func (w *Wxcom) cachedAgent() (*RespAgent, error) {
+	cacheKey := fmt.Sprintf("agent_%d", w.agentid)
+	if value, found := w.cache.Get(cacheKey); found {
+		return value.(*RespAgent), nil
+	}
+
+	resp, err := w.GetAgent()
+	if err != nil {
+		return nil, err
+	}
+	if err = resp.err(); err != nil {
+		return nil, err
+	}
+
+	w.cache.Set(cacheKey, resp, contactCacheExpiration)
+	return resp, nil
+}
+
+// cachedDepartments method list all the departments from cache or server.
+func (w *Wxcom) cachedDepartments() ([]Department, error) {
+	cacheKey := fmt.Sprintf("departments_%d", w.agentid)
+	if value, found := w.cache.Get(cacheKey); found {
+		return value.([]Department), nil
+	}
+
+	resp, err := w.ListDepartments(0)
+	if err != nil {
+		return nil, err
+	}
+	if err = resp.err(); err != nil {
+		return nil, err
+	}
+
+	w.cache.Set(cacheKey, resp.Department, contactCacheExpiration)
+	return resp.Department, nil
+}
+
+// cachedUser method get the user from cache or server.
+// The user not found(60111, 46004) or outside the contact privilege(60011) is cached with the errcode,
+// the other errors are returned.
+func (w *Wxcom) cachedUser(userid string) (*RespUser, error) {
+	cacheKey := fmt.Sprintf("user_%d_%s", w.agentid, userid)
+	if value, found := w.cache.Get(cacheKey); found {
+		return value.(*RespUser), nil
+	}
+
+	resp, err := w.GetUser(userid)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.Errcode {
+	case 0, 60111, 46004, 60011:
+	default:
+		return nil, resp.err()
+	}
+
+	w.cache.Set(cacheKey, resp, contactCacheExpiration)
+	return resp, nil
+}
+
+// RespUserid struct holds response values of get userid by mobile or email.
+type RespUserid struct {
+	respCommon
+	Userid string `json:"userid"`
+}
+
+// GetUseridByMobile method get the userid by mobile.
+func (w *Wxcom) GetUseridByMobile(mobile string) (*RespUserid, error) {
+	response := &RespUserid{}
+
+	err := w.sendWithRetry("/cgi-bin/user/getuserid", nil, map[string]interface{}{"mobile": mobile}, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// GetUseridByEmail method get the userid by email.
+// The emailType is 1 for the corp email, 2 for the personal email.
+func (w *Wxcom) GetUseridByEmail(email string, emailType int) (*RespUserid, error) {
+	response := &RespUserid{}
+
+	err := w.sendWithRetry("/cgi-bin/user/get_userid_by_email", nil, map[string]interface{}{"email": email, "email_type": emailType}, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
@@ -0,0 +1,232 @@
+package wxcom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/patrickmn/go-cache"
+	"time"
+)
+
+const (
+	// DedupAcquired means the key is absent and stored as pending by the call.
+	DedupAcquired = 0
+	// DedupPending means the message of the key is being sent, or the sender has crashed before the send finished.
+	// The outbox keeps retrying the message of the pending key until the key expires.
+	DedupPending = 1
+	// DedupDone means the message of the key has been sent.
+	DedupDone = 2
+)
+
+// DedupStore interface stores the keys of the sent messages, so that the same message is not sent twice.
+// Use a shared store, such as Redis SET NX and GET with expiration, to suppress the duplicates across replicas.
+type DedupStore interface {
+	// Acquire stores the key as pending with the ttl if it is absent, and returns DedupAcquired,
+	// otherwise returns the state of the existing key, DedupPending or DedupDone.
+	Acquire(key string, ttl time.Duration) (int, error)
+	// Done marks the key as sent, it is kept for the ttl.
+	Done(key string, ttl time.Duration) error
+	// Release removes the key, so that the message can be sent again.
+	Release(key string) error
+}
+
+// DuplicateError struct is returned when the message with the same key has been sent within the ttl,
+// or is being sent if Pending is true.
+type DuplicateError struct {
+	Key     string
+	Pending bool
+}
+
+// Error method implements the error interface.
+func (e *DuplicateError) Error() string {
+	if e.Pending {
+		return fmt.Sprintf("duplicate message of key %q is being sent", e.Key)
+	}
+	return fmt.Sprintf("duplicate message of key %q", e.Key)
+}
+
+// deduplicator struct holds the dedup settings of the client.
+type deduplicator struct {
+	store     DedupStore
+	ttl       time.Duration
+	byContent bool
+	onError   func(key string, err error)
+}
+
+// memoryDedupStore struct is the in-memory DedupStore.
+type memoryDedupStore struct {
+	cache *cache.Cache
+}
+
+// NewMemoryDedupStore method creates a new in-memory DedupStore, which only works within the process.
+func NewMemoryDedupStore() DedupStore {
+	return &memoryDedupStore{
+		cache: cache.New(cache.NoExpiration, 10*time.Minute),
+	}
+}
+
+// Acquire method stores the key as pending with the ttl if it is absent, or returns the state of the existing key.
+func (s *memoryDedupStore) Acquire(key string, ttl time.Duration) (int, error) {
+	if s.cache.Add(key, DedupPending, ttl) == nil {
+		return DedupAcquired, nil
+	}
+	if state, found := s.cache.Get(key); found {
+		return state.(int), nil
+	}
+	// expired meanwhile
+	return DedupPending, nil
+}
+
+// Done method marks the key as sent.
+func (s *memoryDedupStore) Done(key string, ttl time.Duration) error {
+	s.cache.Set(key, DedupDone, ttl)
+	return nil
+}
+
+// Release method removes the key.
+func (s *memoryDedupStore) Release(key string) error {
+	s.cache.Delete(key)
+	return nil
+}
+
+// SetDeduplicator method suppresses the message sent with the same key within the ttl.
+//
+// The key is the one set by Message.IdempotencyKey, or the sha256 of the payload if not set.
+// The key is prefixed by the hash of the sender, such as the corpid and agentid, the chatid or the webhook key,
+// so that the clients sharing the store do not suppress the messages of each other.
+// The agents of an AgentPool share the sender of the pool, so that the message is not sent again by another agent.
+// The key is pending during the send, it is marked done when the send succeeds or some batches or parts are delivered,
+// and released when the send fails without delivering, so that the retried send is not suppressed.
+// The undelivered recipients of the partly delivered send are retried by the caller, or by the outbox under a new key.
+func (w *Wxcom) SetDeduplicator(store DedupStore, ttl time.Duration) *Wxcom {
+	w.dedup.store = store
+	w.dedup.ttl = ttl
+	w.dedup.byContent = true
+	return w
+}
+
+// SetDedupByContent method sets whether the message without idempotency key is deduplicated by the payload hash.
+// Disable it if the same content is expected to be sent repeatedly without idempotency key, such as the heartbeats.
+// The scheduled messages are deduplicated by the schedule id and fire time instead.
+func (w *Wxcom) SetDedupByContent(byContent bool) *Wxcom {
+	w.dedup.byContent = byContent
+	return w
+}
+
+// OnDedupError method sets the callback of the keys failed to mark done or release after the send.
+// The key failed to release is pending until the ttl, and the key failed to mark done may be sent again.
+func (w *Wxcom) OnDedupError(fn func(key string, err error)) *Wxcom {
+	w.dedup.onError = fn
+	return w
+}
+
+// SetDeduplicator method suppresses the message sent by the robot with the same key, see Wxcom.SetDeduplicator.
+func (r *Robot) SetDeduplicator(store DedupStore, ttl time.Duration) *Robot {
+	r.wx.SetDeduplicator(store, ttl)
+	return r
+}
+
+// SetDeduplicator method suppresses the message sent by the pool with the same key, see Wxcom.SetDeduplicator.
+func (p *RobotPool) SetDeduplicator(store DedupStore, ttl time.Duration) *RobotPool {
+	p.wx.SetDeduplicator(store, ttl)
+	return p
+}
+
+// OnDedupError method sets the callback of the keys failed to mark done or release, see Wxcom.OnDedupError.
+func (r *Robot) OnDedupError(fn func(key string, err error)) *Robot {
+	r.wx.OnDedupError(fn)
+	return r
+}
+
+// OnDedupError method sets the callback of the keys failed to mark done or release, see Wxcom.OnDedupError.
+func (p *RobotPool) OnDedupError(fn func(key string, err error)) *RobotPool {
+	p.wx.OnDedupError(fn)
+	return p
+}
+
+// IdempotencyKey method sets the key to deduplicate the current message, see Wxcom.SetDeduplicator.
+func (m *Message) IdempotencyKey(key string) *Message {
+	m.idempotencyKey = key
+	return m
+}
+
+// dedupKey method returns the key to deduplicate the message, empty if the message is not deduplicated.
+func (m *Message) dedupKey(opts sendOptions) (string, error) {
+	namespace := m.dedupNamespace(opts)
+	if m.idempotencyKey != "" {
+		return "key:" + namespace + ":" + m.idempotencyKey + opts.dedupSuffix, nil
+	}
+	if !m.wx.dedup.byContent {
+		return "", nil
+	}
+
+	payload, err := m.payload()
+	if err != nil {
+		return "", err
+	}
+	if opts.dedupSender != "" {
+		// the message sent by any agent of the pool is the same
+		payload.Agentid = 0
+	}
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(append([]byte(namespace+"\n"+m.target.path+"\n"), content...))
+	return "content:" + hex.EncodeToString(sum[:]) + opts.dedupSuffix, nil
+}
+
+// dedupNamespace method returns the hash of the sender, the webhook key is not stored in plain.
+func (m *Message) dedupNamespace(opts sendOptions) string {
+	sender := opts.dedupSender
+	if sender == "" {
+		sender = m.target.sender(m.wx)
+	}
+
+	sum := sha256.Sum256([]byte(sender))
+	return hex.EncodeToString(sum[:8])
+}
+
+// acquireDedup method acquires the dedup key of the message, the returned key is released if the send fails.
+func (m *Message) acquireDedup(opts sendOptions) (string, error) {
+	d := m.wx.dedup
+	if d.store == nil {
+		return "", nil
+	}
+
+	key, err := m.dedupKey(opts)
+	if err != nil || key == "" {
+		return "", err
+	}
+
+	state, err := d.store.Acquire(key, d.ttl)
+	if err != nil {
+		return "", err
+	}
+	if state != DedupAcquired {
+		return "", &DuplicateError{Key: key, Pending: state == DedupPending}
+	}
+
+	return key, nil
+}
+
+// finishDedup method marks the dedup key done after the send, or releases it after the failed send.
+// The key is done if some batches or parts have been delivered, so that they are not sent again.
+func (m *Message) finishDedup(key string, response *RespMessage) {
+	if key == "" {
+		return
+	}
+
+	d := m.wx.dedup
+	var err error
+	if response != nil && response.partlyDelivered() {
+		err = d.store.Done(key, d.ttl)
+	} else {
+		err = d.store.Release(key)
+	}
+	if err != nil && d.onError != nil {
+		d.onError(key, err)
+	}
+}
@@ -0,0 +1,267 @@
+package wxcom_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mingzaily/go-wxcom"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWxcom_SetDeduplicator(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123).SetDeduplicator(wxcom.NewMemoryDedupStore(), time.Minute)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.M().ToUser([]string{"user"}).IdempotencyKey("alert-1").Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+
+	// the same key with different content
+	_, err = tempWx.M().ToUser([]string{"user"}).IdempotencyKey("alert-1").Text("other content").Send()
+	var duplicateErr *wxcom.DuplicateError
+	assertEqual(t, errors.As(err, &duplicateErr), true)
+	assertEqual(t, strings.HasPrefix(duplicateErr.Key, "key:"), true)
+	assertEqual(t, strings.HasSuffix(duplicateErr.Key, ":alert-1"), true)
+
+	// the same content without key
+	_, err = tempWx.M().ToUser([]string{"user"}).Text("content").Send()
+	assertEqual(t, err, nil)
+	_, err = tempWx.M().ToUser([]string{"user"}).Text("content").Send()
+	assertEqual(t, errors.As(err, &duplicateErr), true)
+
+	// different recipients
+	_, err = tempWx.M().ToUser([]string{"user2"}).Text("content").Send()
+	assertEqual(t, err, nil)
+
+	tempWx.SetDedupByContent(false)
+	_, err = tempWx.M().ToUser([]string{"user"}).Text("content").Send()
+	assertEqual(t, err, nil)
+}
+
+func TestWxcom_SetDeduplicatorRelease(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123).SetDeduplicator(wxcom.NewMemoryDedupStore(), time.Minute)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	// the failed send releases the key
+	resp, err := tempWx.M().ToUser([]string{"quota_user"}).IdempotencyKey("alert-1").Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 45009)
+
+	resp, err = tempWx.M().ToUser([]string{"user"}).IdempotencyKey("alert-1").Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+
+	_, err = tempWx.M().ToUser([]string{"user"}).IdempotencyKey("alert-1").Text("content").Send()
+	assertNotEqual(t, err, nil)
+}
+
+func TestWxcom_SetDeduplicatorShared(t *testing.T) {
+	ts := createRobotServer(t)
+	defer ts.Close()
+
+	store := wxcom.NewMemoryDedupStore()
+	robot := wxcom.NewRobot("robot_key_1").SetDeduplicator(store, time.Minute)
+	robot.Resty.SetBaseURL(ts.URL)
+	other := wxcom.NewRobot("robot_key_2").SetDeduplicator(store, time.Minute)
+	other.Resty.SetBaseURL(ts.URL)
+
+	_, err := robot.M().IdempotencyKey("alert-1").Text("content").Send()
+	assertEqual(t, err, nil)
+	_, err = robot.M().Text("content").Send()
+	assertEqual(t, err, nil)
+
+	// the robots sharing the store do not suppress each other
+	_, err = other.M().IdempotencyKey("alert-1").Text("content").Send()
+	assertEqual(t, err, nil)
+	_, err = other.M().Text("content").Send()
+	assertEqual(t, err, nil)
+
+	_, err = other.M().Text("content").Send()
+	var duplicateErr *wxcom.DuplicateError
+	assertEqual(t, errors.As(err, &duplicateErr), true)
+	assertEqual(t, strings.Contains(duplicateErr.Key, "robot_key"), false)
+}
+
+func TestMemoryDedupStore(t *testing.T) {
+	store := wxcom.NewMemoryDedupStore()
+
+	state, err := store.Acquire("key", 50*time.Millisecond)
+	assertEqual(t, err, nil)
+	assertEqual(t, state, wxcom.DedupAcquired)
+
+	state, _ = store.Acquire("key", 50*time.Millisecond)
+	assertEqual(t, state, wxcom.DedupPending)
+
+	assertEqual(t, store.Done("key", 50*time.Millisecond), nil)
+	state, _ = store.Acquire("key", 50*time.Millisecond)
+	assertEqual(t, state, wxcom.DedupDone)
+
+	time.Sleep(60 * time.Millisecond)
+	state, _ = store.Acquire("key", 50*time.Millisecond)
+	assertEqual(t, state, wxcom.DedupAcquired)
+
+	assertEqual(t, store.Release("key"), nil)
+	state, _ = store.Acquire("key", 50*time.Millisecond)
+	assertEqual(t, state, wxcom.DedupAcquired)
+}
+
+// pendingDedupStore struct reports the keys pending for the first acquires.
+type pendingDedupStore struct {
+	wxcom.DedupStore
+	pending int32
+}
+
+func (s *pendingDedupStore) Acquire(key string, ttl time.Duration) (int, error) {
+	if atomic.AddInt32(&s.pending, -1) >= 0 {
+		return wxcom.DedupPending, nil
+	}
+	return s.DedupStore.Acquire(key, ttl)
+}
+
+func TestOutbox_DedupPending(t *testing.T) {
+	ts, payloads := createRecordServer(t)
+	defer ts.Close()
+
+	store := &pendingDedupStore{DedupStore: wxcom.NewMemoryDedupStore(), pending: 2}
+	tempWx := wxcom.New("123", "321", 123).SetDeduplicator(store, time.Minute)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	var dead *wxcom.OutboxRecord
+	outbox := tempWx.NewOutbox(wxcom.NewMemoryOutboxStore()).
+		SetBackoff(time.Millisecond, time.Millisecond).
+		OnDeadLetter(func(record *wxcom.OutboxRecord, err error) {
+			dead = record
+		})
+	assertEqual(t, outbox.Start(), nil)
+
+	// the pending key of another send is retried
+	_, err := outbox.Enqueue(tempWx.M().ToUser([]string{"user"}).IdempotencyKey("alert-1").Text("content"))
+	assertEqual(t, err, nil)
+	assertEqual(t, outbox.Close(context.Background()), nil)
+	assertEqual(t, len(payloads()), 1)
+	assertEqual(t, dead == nil, true)
+
+	// the pending key outlasting the max attempts, such as left by a crashed sender, is not dead
+	atomic.StoreInt32(&store.pending, 10)
+	outbox = tempWx.NewOutbox(wxcom.NewMemoryOutboxStore()).
+		SetMaxAttempts(2).
+		SetBackoff(time.Millisecond, time.Millisecond).
+		OnDeadLetter(func(record *wxcom.OutboxRecord, err error) {
+			dead = record
+		})
+	assertEqual(t, outbox.Start(), nil)
+	_, err = outbox.Enqueue(tempWx.M().ToUser([]string{"user"}).IdempotencyKey("alert-2").Text("content"))
+	assertEqual(t, err, nil)
+	assertEqual(t, outbox.Close(context.Background()), nil)
+	assertEqual(t, len(payloads()), 2)
+	assertEqual(t, dead == nil, true)
+
+	// the done key is delivered
+	outbox = tempWx.NewOutbox(wxcom.NewMemoryOutboxStore())
+	assertEqual(t, outbox.Start(), nil)
+	_, err = outbox.Enqueue(tempWx.M().ToUser([]string{"user"}).IdempotencyKey("alert-1").Text("content"))
+	assertEqual(t, err, nil)
+	assertEqual(t, outbox.Close(context.Background()), nil)
+	assertEqual(t, len(payloads()), 2)
+	assertEqual(t, dead == nil, true)
+}
+
+func TestWxcom_SetDeduplicatorPartlyDelivered(t *testing.T) {
+	var firstBatch, failedBatch int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"access_token\":\"token\",\"expires_in\":7200}"))
+		default:
+			body, _ := ioutil.ReadAll(r.Body)
+			if strings.Contains(string(body), "user0|") {
+				atomic.AddInt32(&firstBatch, 1)
+			}
+			if strings.Contains(string(body), "quota_user") && atomic.AddInt32(&failedBatch, 1) == 1 {
+				_, _ = w.Write([]byte("{\"errcode\":45009,\"errmsg\":\"api freq out of limit\"}"))
+				return
+			}
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"msgid\":\"msgid\"}"))
+		}
+	}))
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123).SetDeduplicator(wxcom.NewMemoryDedupStore(), time.Minute)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	var users []string
+	for i := 0; i < 1000; i++ {
+		users = append(users, fmt.Sprintf("user%d", i))
+	}
+	users = append(users, "quota_user")
+
+	// the key is done once some batches are delivered
+	resp, err := tempWx.M().ToUser(users).IdempotencyKey("alert-1").Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 45009)
+	_, err = tempWx.M().ToUser(users).IdempotencyKey("alert-1").Text("content").Send()
+	var duplicateErr *wxcom.DuplicateError
+	assertEqual(t, errors.As(err, &duplicateErr), true)
+
+	// the outbox retries the failed batch only
+	atomic.StoreInt32(&firstBatch, 0)
+	atomic.StoreInt32(&failedBatch, 0)
+	outbox := tempWx.NewOutbox(wxcom.NewMemoryOutboxStore()).SetBackoff(time.Millisecond, time.Millisecond)
+	assertEqual(t, outbox.Start(), nil)
+	_, err = outbox.Enqueue(tempWx.M().ToUser(users).IdempotencyKey("alert-2").Text("content"))
+	assertEqual(t, err, nil)
+	assertEqual(t, outbox.Close(context.Background()), nil)
+
+	assertEqual(t, atomic.LoadInt32(&firstBatch), int32(1))
+	assertEqual(t, atomic.LoadInt32(&failedBatch), int32(2))
+}
+
+// failingDedupStore struct fails to mark done or release the keys.
+type failingDedupStore struct {
+	wxcom.DedupStore
+}
+
+func (s *failingDedupStore) Done(key string, ttl time.Duration) error {
+	return errors.New("done failed")
+}
+
+func (s *failingDedupStore) Release(key string) error {
+	return errors.New("release failed")
+}
+
+func TestWxcom_OnDedupError(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	var errs []string
+	tempWx := wxcom.New("123", "321", 123).
+		SetDeduplicator(&failingDedupStore{DedupStore: wxcom.NewMemoryDedupStore()}, time.Minute).
+		OnDedupError(func(key string, err error) {
+			assertEqual(t, key != "", true)
+			errs = append(errs, err.Error())
+		})
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.M().ToUser([]string{"user"}).IdempotencyKey("alert-1").Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+
+	resp, err = tempWx.M().ToUser([]string{"quota_user"}).IdempotencyKey("alert-2").Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 45009)
+
+	assertEqual(t, errs, []string{"done failed", "release failed"})
+}
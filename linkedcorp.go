@@ -0,0 +1,105 @@
+package wxcom
+
+import (
+	"strconv"
+	"strings"
+)
+
+// linkedcorpSendPath is the path of sending message to the members of the linked corps.
+const linkedcorpSendPath = "/cgi-bin/linkedcorp/message/send"
+
+// LinkedcorpPayload struct holds the request payload of send linkedcorp message,
+// whose recipients are arrays instead of strings joined by "|".
+type LinkedcorpPayload struct {
+	*MessagePayload
+	Toall   int      `json:"toall,omitempty"`
+	Toparty []string `json:"toparty,omitempty"`
+	Totag   []string `json:"totag,omitempty"`
+	Touser  []string `json:"touser,omitempty"`
+}
+
+// RespLinkedcorpMessage struct holds response values of send linkedcorp message.
+type RespLinkedcorpMessage struct {
+	respCommon
+	Invaliduser  []string `json:"invaliduser"`
+	Invalidparty []string `json:"invalidparty"`
+	Invalidtag   []string `json:"invalidtag"`
+}
+
+// Linkedcorp method creates a new Message instance sent to the members of the linked corps by `linkedcorp/message/send`.
+//
+// The users are "CorpId/UserId" and the parties are "LinkedId/DepartmentId", see LinkedcorpUser and LinkedcorpParty.
+// The message supports text, image, voice, video, file, textcard, news and markdown,
+// and ToAll sends to all the members in the linked scope of the agent.
+func (w *Wxcom) Linkedcorp() *Message {
+	return &Message{
+		wx:     w,
+		target: linkedcorpTarget(),
+	}
+}
+
+// NewLinkedcorpMessage is an alias for method `Linkedcorp()`. Creates a new Message instance sent to the linked corps.
+func (w *Wxcom) NewLinkedcorpMessage() *Message {
+	return w.Linkedcorp()
+}
+
+// LinkedcorpUser method returns the cross-corp userid as "CorpId/UserId".
+func LinkedcorpUser(corpid, userid string) string {
+	return corpid + "/" + userid
+}
+
+// LinkedcorpUsers method returns the cross-corp userids of the same corp.
+func LinkedcorpUsers(corpid string, userids []string) []string {
+	users := make([]string, len(userids))
+	for i, userid := range userids {
+		users[i] = LinkedcorpUser(corpid, userid)
+	}
+	return users
+}
+
+// LinkedcorpParty method returns the cross-corp party id as "LinkedId/DepartmentId".
+func LinkedcorpParty(linkedid string, departmentId int) string {
+	return linkedid + "/" + strconv.Itoa(departmentId)
+}
+
+// linkedcorpPayload method converts the payload into the linkedcorp payload.
+func (m *Message) linkedcorpPayload(payload *MessagePayload) *LinkedcorpPayload {
+	linked := &LinkedcorpPayload{
+		MessagePayload: payload,
+		Toparty:        m.toParty,
+		Totag:          m.toTag,
+		Touser:         m.toUser,
+	}
+	if len(m.toUser) == 1 && m.toUser[0] == allRecipient {
+		linked.Toall = 1
+		linked.Touser = nil
+	}
+	payload.EnableIdTrans = nil
+	return linked
+}
+
+// sendLinkedcorp method sends the linkedcorp payload, and converts the response into RespMessage.
+func (m *Message) sendLinkedcorp(payload *MessagePayload, response *RespMessage) error {
+	linkedResponse := &RespLinkedcorpMessage{}
+
+	err := m.wx.sendWithRetry(m.target.path, nil, m.linkedcorpPayload(payload), linkedResponse)
+	if err != nil {
+		return err
+	}
+
+	response.respCommon = linkedResponse.respCommon
+	response.Invaliduser = strings.Join(linkedResponse.Invaliduser, "|")
+	response.Invalidparty = strings.Join(linkedResponse.Invalidparty, "|")
+	response.Invalidtag = strings.Join(linkedResponse.Invalidtag, "|")
+	return nil
+}
+
+// validateLinkedcorpIds method checks the cross-corp ids are "CorpId/Id".
+func validateLinkedcorpIds(v *ValidationError, field string, ids []string) {
+	for _, id := range ids {
+		if i := strings.Index(id, "/"); i <= 0 || i == len(id)-1 {
+			v.add(field, "%s must be \"CorpId/Id\", got %q", field, id)
+			return
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package wxcom_test
+
+import (
+	"github.com/mingzaily/go-wxcom"
+	"testing"
+)
+
+func TestLinkedcorpIds(t *testing.T) {
+	assertEqual(t, wxcom.LinkedcorpUser("corp1", "user1"), "corp1/user1")
+	assertEqual(t, wxcom.LinkedcorpUsers("corp1", []string{"user1", "user2"}), []string{"corp1/user1", "corp1/user2"})
+	assertEqual(t, wxcom.LinkedcorpParty("linked1", 2), "linked1/2")
+}
+
+func TestWxcom_Linkedcorp(t *testing.T) {
+	msg := wx.Linkedcorp().
+		ToUser(wxcom.LinkedcorpUsers("corp1", []string{"user1", "user2"})).
+		ToParty([]string{wxcom.LinkedcorpParty("linked1", 2)}).
+		ToTag([]string{"3"})
+
+	assertJson(t, msg.Text("content").SetSafe(1).SetEnableIdTrans(1),
+		"{\"agentid\":1,\"msgtype\":\"text\",\"safe\":1,\"text\":{\"content\":\"content\"},\"toparty\":[\"linked1/2\"],\"totag\":[\"3\"],\"touser\":[\"corp1/user1\",\"corp1/user2\"]}")
+	assertJson(t, wx.Linkedcorp().ToAll().Markdown("**content**"),
+		"{\"agentid\":1,\"markdown\":{\"content\":\"**content**\"},\"msgtype\":\"markdown\",\"toall\":1}")
+
+	err := wx.Linkedcorp().ToUser([]string{"user1"}).Text("content").Validate()
+	assertEqual(t, err.Error(), "touser must be \"CorpId/Id\", got \"user1\"")
+
+	err = msg.Clone().DuplicateCheck(1, 10).Text("content").Validate()
+	assertEqual(t, err.Error(), "duplicate check is not supported by linkedcorp")
+
+	err = msg.TemplateCard(&wxcom.TemplateCard{CardType: "text_notice"}).Validate()
+	assertEqual(t, err.Error(), "template card is not supported by linkedcorp")
+}
+
+func TestWxcom_LinkedcorpSend(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.NewLinkedcorpMessage().ToUser([]string{"corp1/user1", "corp1/invalid_user"}).Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+	assertEqual(t, resp.Invaliduser, "corp1/invalid_user")
+	assertEqual(t, resp.Result().Delivered().Users, []string{"corp1/user1"})
+
+	_, err = tempWx.NewOutbox(wxcom.NewMemoryOutboxStore()).Enqueue(tempWx.Linkedcorp().ToAll().Text("content"))
+	assertEqual(t, err.Error(), "linkedcorp message cannot be persisted")
+}
@@ -0,0 +1,187 @@
+package wxcom
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FontColor is the color of the font tag in WeCom markdown.
+type FontColor string
+
+const (
+	// FontColorInfo is green.
+	FontColorInfo FontColor = "info"
+	// FontColorComment is gray.
+	FontColorComment FontColor = "comment"
+	// FontColorWarning is orange red.
+	FontColorWarning FontColor = "warning"
+)
+
+// defaultMarkdownLimit is the max bytes of the markdown content accepted by the group robot.
+const defaultMarkdownLimit = 4096
+
+// markdownEscaper escapes the characters which have meanings in WeCom markdown.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`*`, `\*`,
+	`_`, `\_`,
+	"`", "\\`",
+	`[`, `\[`,
+	`]`, `\]`,
+	`<`, `&lt;`,
+	`>`, `&gt;`,
+)
+
+// MarkdownBuilder struct is used to build the content in WeCom markdown dialect.
+//
+// The user content passed to the methods is escaped, use Raw to append the content as is.
+// Once the content would exceed the limit, it and the following content are not appended, and the error is kept in Err.
+type MarkdownBuilder struct {
+	sb    strings.Builder
+	limit int
+	err   error
+}
+
+// NewMarkdownBuilder method creates a new MarkdownBuilder with the limit of 4096 bytes.
+// Use SetLimit(2048) for the application message.
+func NewMarkdownBuilder() *MarkdownBuilder {
+	return &MarkdownBuilder{
+		limit: defaultMarkdownLimit,
+	}
+}
+
+// SetLimit method sets the max bytes of the content.
+func (b *MarkdownBuilder) SetLimit(limit int) *MarkdownBuilder {
+	b.limit = limit
+	return b
+}
+
+// Raw method appends the content as is.
+func (b *MarkdownBuilder) Raw(content string) *MarkdownBuilder {
+	if b.err != nil {
+		return b
+	}
+	if b.sb.Len()+len(content) > b.limit {
+		b.err = fmt.Errorf("markdown content cannot exceed %d bytes", b.limit)
+		return b
+	}
+	b.sb.WriteString(content)
+	return b
+}
+
+// Text method appends the escaped text.
+func (b *MarkdownBuilder) Text(text string) *MarkdownBuilder {
+	return b.Raw(EscapeMarkdown(text))
+}
+
+// Line method appends the escaped text and a line break.
+func (b *MarkdownBuilder) Line(text string) *MarkdownBuilder {
+	return b.Raw(EscapeMarkdown(text) + "\n")
+}
+
+// Heading method appends a heading line of the level from 1 to 6.
+func (b *MarkdownBuilder) Heading(level int, text string) *MarkdownBuilder {
+	if level < 1 {
+		level = 1
+	}
+	if level > 6 {
+		level = 6
+	}
+	return b.Raw(b.lineStart() + strings.Repeat("#", level) + " " + EscapeMarkdown(text) + "\n")
+}
+
+// Bold method appends the bold text.
+func (b *MarkdownBuilder) Bold(text string) *MarkdownBuilder {
+	return b.Raw("**" + EscapeMarkdown(text) + "**")
+}
+
+// Link method appends the link.
+func (b *MarkdownBuilder) Link(text, url string) *MarkdownBuilder {
+	url = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(url)
+	return b.Raw("[" + EscapeMarkdown(text) + "](" + url + ")")
+}
+
+// Code method appends the inline code.
+func (b *MarkdownBuilder) Code(code string) *MarkdownBuilder {
+	code = strings.ReplaceAll(code, "\n", " ")
+	if strings.Contains(code, "`") {
+		return b.Raw("`` " + code + " ``")
+	}
+	return b.Raw("`" + code + "`")
+}
+
+// Quote method appends the quote lines.
+func (b *MarkdownBuilder) Quote(text string) *MarkdownBuilder {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = "> " + EscapeMarkdown(line)
+	}
+	return b.Raw(b.lineStart() + strings.Join(lines, "\n") + "\n")
+}
+
+// Font method appends the text in the color.
+func (b *MarkdownBuilder) Font(color FontColor, text string) *MarkdownBuilder {
+	return b.Raw(`<font color="` + string(color) + `">` + EscapeMarkdown(text) + `</font>`)
+}
+
+// Info method appends the green text.
+func (b *MarkdownBuilder) Info(text string) *MarkdownBuilder {
+	return b.Font(FontColorInfo, text)
+}
+
+// Comment method appends the gray text.
+func (b *MarkdownBuilder) Comment(text string) *MarkdownBuilder {
+	return b.Font(FontColorComment, text)
+}
+
+// Warning method appends the orange red text.
+func (b *MarkdownBuilder) Warning(text string) *MarkdownBuilder {
+	return b.Font(FontColorWarning, text)
+}
+
+// Mention method appends the @mention of the user.
+func (b *MarkdownBuilder) Mention(userid string) *MarkdownBuilder {
+	return b.Raw("<@" + userid + ">")
+}
+
+// Len method returns the bytes of the content.
+func (b *MarkdownBuilder) Len() int {
+	return b.sb.Len()
+}
+
+// Remaining method returns the bytes can be appended before reaching the limit.
+func (b *MarkdownBuilder) Remaining() int {
+	return b.limit - b.sb.Len()
+}
+
+// Err method returns the error if some content is not appended because of the limit.
+func (b *MarkdownBuilder) Err() error {
+	return b.err
+}
+
+// String method returns the content.
+func (b *MarkdownBuilder) String() string {
+	return b.sb.String()
+}
+
+// lineStart method returns a line break if the content does not end with one.
+func (b *MarkdownBuilder) lineStart() string {
+	if b.sb.Len() == 0 || strings.HasSuffix(b.sb.String(), "\n") {
+		return ""
+	}
+	return "\n"
+}
+
+// EscapeMarkdown method escapes the text to be displayed as is in WeCom markdown.
+func EscapeMarkdown(text string) string {
+	text = markdownEscaper.Replace(text)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "-") || strings.HasPrefix(trimmed, "+") {
+			lines[i] = line[:len(line)-len(trimmed)] + `\` + trimmed
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,50 @@
+package wxcom_test
+
+import (
+	"github.com/mingzaily/go-wxcom"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownBuilder(t *testing.T) {
+	b := wxcom.NewMarkdownBuilder().
+		Heading(2, "发布完成").
+		Text("服务：").Bold("api*server").Line("").
+		Text("状态：").Info("成功").Line("").
+		Text("耗时：").Comment("3m").Line("").
+		Text("告警：").Warning("<none>").Line("").
+		Link("详情", "https://test.com/a (b)").Line("").
+		Code("go test ./...").Line("").
+		Quote("# 引用\n第二行").
+		Mention("test")
+
+	assertEqual(t, b.Err(), nil)
+	assertEqual(t, b.String(),
+		"## 发布完成\n"+
+			"服务：**api\\*server**\n"+
+			"状态：<font color=\"info\">成功</font>\n"+
+			"耗时：<font color=\"comment\">3m</font>\n"+
+			"告警：<font color=\"warning\">&lt;none&gt;</font>\n"+
+			"[详情](https://test.com/a%20%28b%29)\n"+
+			"`go test ./...`\n"+
+			"> \\# 引用\n> 第二行\n"+
+			"<@test>")
+}
+
+func TestMarkdownBuilder_Limit(t *testing.T) {
+	b := wxcom.NewMarkdownBuilder().SetLimit(10).Text("12345")
+
+	assertEqual(t, b.Len(), 5)
+	assertEqual(t, b.Remaining(), 5)
+
+	b.Text("123456").Text("1")
+	assertEqual(t, b.String(), "12345")
+	assertEqual(t, b.Err().Error(), "markdown content cannot exceed 10 bytes")
+
+	b = wxcom.NewMarkdownBuilder().Text(strings.Repeat("a", 4096))
+	assertEqual(t, b.Err(), nil)
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	assertEqual(t, wxcom.EscapeMarkdown("a_b [c](d) `e`\n- f"), "a\\_b \\[c\\](d) \\`e\\`\n\\- f")
+}
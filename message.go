@@ -2,19 +2,28 @@ package wxcom
 
 import (
 	"encoding/json"
-	"errors"
-	"log"
 	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxUsersPerSend is the max count of users WeCom accepts in one send.
+	maxUsersPerSend = 1000
+	// maxPartiesPerSend is the max count of parties WeCom accepts in one send.
+	maxPartiesPerSend = 100
+	// maxTagsPerSend is the max count of tags WeCom accepts in one send.
+	maxTagsPerSend = 100
 )
 
 // Message struct is used to compose and fire individual message client.
 type Message struct {
 	wx                     *Wxcom
-	path                   string
+	target                 messageTarget
 	msgType                string
-	toUser                 string
-	toParty                string
-	toTag                  string
+	toUser                 []string
+	toParty                []string
+	toTag                  []string
 	safe                   int
 	content                string
 	mediaId                string
@@ -27,33 +36,66 @@ type Message struct {
 	btnTxt                 string
 	enableDuplicateCheck   int
 	duplicateCheckInterval int
+	templateCard           *TemplateCard
+	articles               []NewsArticle
+	autoSplit              bool
+	correlationId          string
+	concurrency            int
+	strictDelivery         bool
+	strictScope            bool
+	mentionedList          []string
+	mentionedMobileList    []string
+	imageData              []byte
+	idempotencyKey         string
+}
+
+// sendOptions struct holds the state of the send set by the outbox and the agent pool instead of the builder.
+type sendOptions struct {
+	// nextPart is the index of the first part to send, the parts before it have been delivered.
+	nextPart int
+	// partRecipients are the recipients of the first part not delivered yet, nil for all the recipients.
+	partRecipients *Recipients
+	// dedupSuffix is appended to the dedup key, so that the rest of the partly delivered message is not suppressed.
+	dedupSuffix string
+	// dedupSender replaces the sender of the dedup key, so that the agents of the pool share the keys.
+	dedupSender string
 }
 
 // RespMessage struct holds response values of send message.
+// Use method Result to get the invalid recipients as slices.
 type RespMessage struct {
 	respCommon
-	Invaliduser  string `json:"invaliduser"`
-	Invalidparty string `json:"invalidparty"`
-	Invalidtag   string `json:"invalidtag"`
-	Msgid        string `json:"msgid"`
-	ResponseCode string `json:"response_code"`
+	Invaliduser    string `json:"invaliduser"`
+	Invalidparty   string `json:"invalidparty"`
+	Invalidtag     string `json:"invalidtag"`
+	Unlicenseduser string `json:"unlicenseduser"`
+	Msgid          string `json:"msgid"`
+	ResponseCode   string `json:"response_code"`
+	// Msgids holds the msgids of all the batches when the recipients are split into several batches.
+	Msgids    []string `json:"-"`
+	requested Recipients
+	failed    Recipients
+	failures  int
+	batches   int
+	// nextPart is the index of the first part not delivered to all the recipients when the content is split.
+	nextPart int
 }
 
 // ToUser method sets to user to in the current message.
 func (m *Message) ToUser(userList []string) *Message {
-	m.toUser = strings.Join(userList, "|")
+	m.toUser = userList
 	return m
 }
 
 // ToParty method sets to party to in the current message.
 func (m *Message) ToParty(partyList []string) *Message {
-	m.toParty = strings.Join(partyList, "|")
+	m.toParty = partyList
 	return m
 }
 
 // ToTag method sets to tag to in the current message.
 func (m *Message) ToTag(tagList []string) *Message {
-	m.toTag = strings.Join(tagList, "|")
+	m.toTag = tagList
 	return m
 }
 
@@ -69,6 +111,37 @@ func (m *Message) DuplicateCheck(enableDuplicateCheck, duplicateCheckInterval in
 	return m
 }
 
+// CorrelationId method sets the correlation id of the current message.
+// The msgid is recorded in the send log of the client under the correlation id, see Wxcom.SetSendLog.
+func (m *Message) CorrelationId(correlationId string) *Message {
+	m.correlationId = correlationId
+	return m
+}
+
+// Concurrency method sets how many batches are sent at the same time
+// when the recipients exceed the WeCom limits and are split into several batches.
+// The batches are sent one by one by default.
+func (m *Message) Concurrency(concurrency int) *Message {
+	m.concurrency = concurrency
+	return m
+}
+
+// StrictDelivery method makes Send return a *PartialFailureError
+// together with the response when some of the recipients are not delivered.
+func (m *Message) StrictDelivery() *Message {
+	m.strictDelivery = true
+	return m
+}
+
+// recipients method returns the recipients of the message.
+func (m *Message) recipients() Recipients {
+	return Recipients{
+		Users:   m.toUser,
+		Parties: m.toParty,
+		Tags:    m.toTag,
+	}
+}
+
 // Clone method create the new message client.
 func (m *Message) Clone() *Message {
 	return m.clone()
@@ -80,101 +153,323 @@ func (m *Message) clone() *Message {
 	return &newMessage
 }
 
-// genRequestParam method generate http request params.
-func (m *Message) genRequestParam() (map[string]interface{}, error) {
-
-	if m.toUser == "" && m.toParty == "" && m.toTag == "" {
-		return nil, errors.New("toUser, toParty, toTag cannot be empty at the same time")
-	}
-
-	body := map[string]interface{}{
-		"agentid": m.wx.agentid,
-	}
-	if m.toUser != "" {
-		body["touser"] = m.toUser
-	}
-	if m.toParty != "" {
-		body["toparty"] = m.toParty
-	}
-	if m.toTag != "" {
-		body["totag"] = m.toTag
-	}
-	if m.enableDuplicateCheck != 0 {
-		body["enable_duplicate_check"] = m.enableDuplicateCheck
-	}
-	if m.enableDuplicateCheck != 0 {
-		body["duplicate_check_interval"] = m.duplicateCheckInterval
-	}
-
-	switch m.msgType {
-	case "text":
-		body["text"] = map[string]string{"content": m.content}
-		body["safe"] = m.safe
-		body["enable_id_trans"] = m.enableIdTrans
-	case "image":
-		body["msgtype"] = "image"
-		body["image"] = map[string]string{"media_id": m.mediaId}
-		body["safe"] = m.safe
-	case "voice":
-		body["msgtype"] = "voice"
-		body["voice"] = map[string]string{"media_id": m.mediaId}
-	case "video":
-		body["msgtype"] = "video"
-		body["video"] = map[string]string{"media_id": m.mediaId, "title": m.title, "description": m.description}
-		body["safe"] = m.safe
-	case "file":
-		body["msgtype"] = "file"
-		body["file"] = map[string]string{"media_id": m.mediaId}
-		body["safe"] = m.safe
-	case "textcard":
-		body["msgtype"] = "textcard"
-		body["textcard"] = map[string]string{"title": m.title, "description": m.description, "url": m.url, "btntxt": m.btnTxt}
-		body["enable_id_trans"] = m.enableIdTrans
-	case "markdown":
-		body["msgtype"] = "markdown"
-		body["markdown"] = map[string]string{"content": m.content}
-	default:
-		return nil, errors.New("unsupported msg type")
+// toJson method return message string.
+// The message is validated, and the recipients must be within the WeCom limits of one request.
+// The json array of the parts is returned if the content is split.
+func (m *Message) toJson() (string, error) {
+	err := m.validateSingleRequest()
+	if err != nil {
+		return "", err
+	}
+
+	parts := m.parts()
+	payloads := make([]interface{}, len(parts))
+	for i, part := range parts {
+		payload, err := part.payload()
+		if err != nil {
+			return "", err
+		}
+		payloads[i] = payload
+		if m.target.kind == targetLinkedcorp {
+			payloads[i] = part.linkedcorpPayload(payload)
+		}
 	}
 
-	body["msgtype"] = m.msgType
+	var paramBytes []byte
+	if len(payloads) == 1 {
+		paramBytes, err = json.Marshal(payloads[0])
+	} else {
+		paramBytes, err = json.Marshal(payloads)
+	}
+	if err != nil {
+		return "", err
+	}
 
-	return body, nil
+	return string(paramBytes), nil
 }
 
-// toJson method return message string.
-func (m *Message) toJson() string {
-	param, err := m.genRequestParam()
+// batches method splits the message into several messages whose recipients are within the WeCom limits.
+func (m *Message) batches() []*Message {
+	count := 1
+	for _, n := range []int{
+		chunkCount(len(m.toUser), maxUsersPerSend),
+		chunkCount(len(m.toParty), maxPartiesPerSend),
+		chunkCount(len(m.toTag), maxTagsPerSend),
+	} {
+		if n > count {
+			count = n
+		}
+	}
+
+	if count == 1 {
+		return []*Message{m}
+	}
+
+	batches := make([]*Message, count)
+	for i := range batches {
+		batch := m.clone()
+		batch.toUser = chunk(m.toUser, maxUsersPerSend, i)
+		batch.toParty = chunk(m.toParty, maxPartiesPerSend, i)
+		batch.toTag = chunk(m.toTag, maxTagsPerSend, i)
+		batches[i] = batch
+	}
+
+	return batches
+}
+
+// send method does send message.
+// The recipients exceeding the WeCom limits are split into several batches,
+// and the responses of the batches are merged into one.
+func (m *Message) send(opts sendOptions) (*RespMessage, error) {
+	start := time.Now()
+	response, err := m.deliver(opts)
+	m.audit(start, response, err)
+	return response, err
+}
+
+// deliver method does validate and send message.
+func (m *Message) deliver(opts sendOptions) (*RespMessage, error) {
+	err := m.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if m.strictScope {
+		report, err := m.Preflight()
+		if err != nil {
+			return nil, err
+		}
+		if !report.InScope() {
+			return nil, &ScopeError{Report: report}
+		}
+	}
+
+	dedupKey, err := m.acquireDedup(opts)
 	if err != nil {
-		return ""
+		return nil, err
 	}
 
-	paramBytes, err := json.Marshal(param)
+	response, err := m.sendParts(opts)
+	m.finishDedup(dedupKey, response)
 	if err != nil {
-		log.Fatalln(err)
-		return ""
+		return response, err
+	}
+
+	if m.strictDelivery {
+		if result := response.Result(); result.PartialFailure() {
+			return response, &PartialFailureError{Result: result}
+		}
 	}
 
-	return string(paramBytes)
+	return response, nil
 }
 
-// send method does send message.
-func (m *Message) send() (*RespMessage, error) {
+// sendParts method does send the parts of the message in order.
+// The parts after the failed one are not sent, so that the recipients get the parts in order.
+func (m *Message) sendParts(opts sendOptions) (*RespMessage, error) {
+	parts := m.parts()
+	if len(parts) == 1 && opts.partRecipients == nil {
+		return m.sendBatches()
+	}
+
+	responses := make([]*RespMessage, 0, len(parts))
+	for i := opts.nextPart; i < len(parts); i++ {
+		part := parts[i]
+		if i == opts.nextPart && opts.partRecipients != nil {
+			// the part has been delivered to the other recipients
+			part = part.clone()
+			part.toUser = opts.partRecipients.Users
+			part.toParty = opts.partRecipients.Parties
+			part.toTag = opts.partRecipients.Tags
+		}
+
+		response, err := part.sendBatches()
+		responses = append(responses, response)
+		if err != nil || response.Errcode != 0 {
+			merged := mergeRespMessage(responses)
+			merged.nextPart = i
+			return merged, err
+		}
+	}
+
+	merged := mergeRespMessage(responses)
+	merged.nextPart = len(parts)
+	return merged, nil
+}
+
+// sendBatches method does send the batches of the message.
+func (m *Message) sendBatches() (*RespMessage, error) {
+	batches := m.batches()
+	if len(batches) == 1 {
+		return m.sendOnce()
+	}
+
+	responses := make([]*RespMessage, len(batches))
+	errs := make([]error, len(batches))
+	parallel(len(batches), m.concurrency, func(i int) {
+		responses[i], errs[i] = batches[i].sendOnce()
+		if responses[i] == nil {
+			responses[i] = batches[i].failedResponse()
+		}
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			return mergeRespMessage(responses), err
+		}
+	}
+
+	return mergeRespMessage(responses), nil
+}
+
+// sendOnce method does send message in one request.
+func (m *Message) sendOnce() (*RespMessage, error) {
 	response := &RespMessage{}
 
-	body, err := m.genRequestParam()
+	payload, err := m.payload()
 	if err != nil {
 		return nil, err
 	}
 
-	err = m.wx.sendWithRetry(m.path, nil, body, response)
+	switch m.target.kind {
+	case targetRobot:
+		err = m.target.robot.send(m.target.path, payload, response)
+	case targetLinkedcorp:
+		err = m.sendLinkedcorp(payload, response)
+	default:
+		err = m.wx.sendWithRetry(m.target.path, nil, payload, response)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if response.Msgid != "" {
+		response.Msgids = []string{response.Msgid}
+	}
+	response.requested = m.recipients()
+	response.batches = 1
+	if response.Errcode != 0 {
+		response.failed = response.requested
+		response.failures = 1
+	}
+
+	if m.wx.sendLog != nil && m.correlationId != "" && response.Msgid != "" {
+		// the message has been sent, so that the send is not failed by the send log
+		err = m.wx.sendLog.Record(m.correlationId, response.Msgid)
+		if err != nil && m.wx.onSendLogError != nil {
+			m.wx.onSendLogError(m.correlationId, response.Msgid, err)
+		}
+	}
+
 	return response, nil
 }
 
+// mergeRespMessage method merges the responses of the batches.
+// The first failed batch decides the errcode and errmsg, the msgid is the one of the first batch.
+// The recipients of the failed batches are kept, so that they are not reported as delivered.
+func mergeRespMessage(responses []*RespMessage) *RespMessage {
+	merged := &RespMessage{}
+
+	var invalidUser, invalidParty, invalidTag, unlicensedUser []string
+	for _, resp := range responses {
+		if resp == nil {
+			continue
+		}
+		if merged.Errcode == 0 {
+			merged.respCommon = resp.respCommon
+		}
+		if merged.Msgid == "" {
+			merged.Msgid = resp.Msgid
+		}
+		if merged.ResponseCode == "" {
+			merged.ResponseCode = resp.ResponseCode
+		}
+		merged.Msgids = append(merged.Msgids, resp.Msgids...)
+		merged.requested = appendRecipients(merged.requested, resp.requested)
+		merged.failed = appendRecipients(merged.failed, resp.failed)
+		merged.failures += resp.failures
+		merged.batches += resp.batches
+		invalidUser = appendNotEmpty(invalidUser, resp.Invaliduser)
+		invalidParty = appendNotEmpty(invalidParty, resp.Invalidparty)
+		invalidTag = appendNotEmpty(invalidTag, resp.Invalidtag)
+		unlicensedUser = appendNotEmpty(unlicensedUser, resp.Unlicenseduser)
+	}
+	merged.Invaliduser = strings.Join(invalidUser, "|")
+	merged.Invalidparty = strings.Join(invalidParty, "|")
+	merged.Invalidtag = strings.Join(invalidTag, "|")
+	merged.Unlicenseduser = strings.Join(unlicensedUser, "|")
+
+	return merged
+}
+
+// partlyDelivered method reports whether some batches of the parts sent have been delivered.
+// The batches are counted instead of the msgids, since the linkedcorp, appchat and robot responses have no msgid.
+func (r *RespMessage) partlyDelivered() bool {
+	return r.failures < r.batches
+}
+
+// failedResponse method returns the response of the batch failed without response, such as the network error.
+func (m *Message) failedResponse() *RespMessage {
+	return &RespMessage{requested: m.recipients(), failed: m.recipients(), failures: 1, batches: 1}
+}
+
+// appendRecipients method appends the recipients to the list.
+func appendRecipients(list, recipients Recipients) Recipients {
+	return Recipients{
+		Users:   append(list.Users, recipients.Users...),
+		Parties: append(list.Parties, recipients.Parties...),
+		Tags:    append(list.Tags, recipients.Tags...),
+	}
+}
+
+// appendNotEmpty method appends the value to the list if it is not empty.
+func appendNotEmpty(list []string, value string) []string {
+	if value == "" {
+		return list
+	}
+	return append(list, value)
+}
+
+// chunkCount method returns the count of chunks of the given size.
+func chunkCount(length, size int) int {
+	return (length + size - 1) / size
+}
+
+// chunk method returns the i-th chunk of the given size, nil if out of range.
+func chunk(list []string, size, i int) []string {
+	start := i * size
+	if start >= len(list) {
+		return nil
+	}
+	end := start + size
+	if end > len(list) {
+		end = len(list)
+	}
+	return list[start:end]
+}
+
+// parallel method calls fn for 0 to n-1 with at most concurrency goroutines, and waits for them.
+func parallel(n, concurrency int, fn func(i int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
 // Text method creates text message.
 func (m *Message) Text(content string) *text {
 	return &text{
@@ -191,6 +486,14 @@ func (m *Message) Image(mediaId string) *image {
 	}
 }
 
+// ImageData method creates image message from the jpg or png data, which is sent as base64 with md5. Only for robot.
+func (m *Message) ImageData(data []byte) *image {
+	return &image{
+		message: m,
+		data:    data,
+	}
+}
+
 // Voice method creates voice message.
 func (m *Message) Voice(mediaId string) *voice {
 	return &voice{
@@ -225,6 +528,14 @@ func (m *Message) Textcard(title, description, url string) *textcard {
 	}
 }
 
+// News method creates news message with 1 to 8 articles.
+func (m *Message) News(articles []NewsArticle) *news {
+	return &news{
+		message:  m,
+		articles: articles,
+	}
+}
+
 // Markdown method creates markdown message.
 func (m *Message) Markdown(content string) *markdown {
 	return &markdown{
@@ -232,3 +543,19 @@ func (m *Message) Markdown(content string) *markdown {
 		content: content,
 	}
 }
+
+// MarkdownV2 method creates markdown_v2 message, which supports tables, lists and images. Only for robot.
+func (m *Message) MarkdownV2(content string) *markdownV2 {
+	return &markdownV2{
+		message: m,
+		content: content,
+	}
+}
+
+// TemplateCard method creates template card message.
+func (m *Message) TemplateCard(card *TemplateCard) *templateCard {
+	return &templateCard{
+		message: m,
+		card:    card,
+	}
+}
@@ -2,10 +2,13 @@ package wxcom
 
 // text struct is used to compose text message push from message client.
 type text struct {
-	message       *Message
-	content       string
-	safe          int
-	enableIdTrans int
+	message             *Message
+	content             string
+	safe                int
+	enableIdTrans       int
+	autoSplit           bool
+	mentionedList       []string
+	mentionedMobileList []string
 }
 
 // build method create the new Message client.
@@ -15,6 +18,9 @@ func (t *text) build() *Message {
 	msg.content = t.content
 	msg.safe = t.safe
 	msg.enableIdTrans = t.enableIdTrans
+	msg.autoSplit = t.autoSplit
+	msg.mentionedList = t.mentionedList
+	msg.mentionedMobileList = t.mentionedMobileList
 	return msg
 }
 
@@ -30,20 +36,45 @@ func (t *text) SetEnableIdTrans(enableIdTrans int) *text {
 	return t
 }
 
+// SetAutoSplit method splits the content exceeding 2048 bytes into several messages on the line or rune boundaries.
+// The parts are numbered as "(1/3)" and sent in order.
+func (t *text) SetAutoSplit(autoSplit bool) *text {
+	t.autoSplit = autoSplit
+	return t
+}
+
+// SetMentionedList method sets the userids mentioned in the group, "@all" mentions everyone. Only for robot.
+func (t *text) SetMentionedList(mentionedList []string) *text {
+	t.mentionedList = mentionedList
+	return t
+}
+
+// SetMentionedMobileList method sets the mobiles mentioned in the group, "@all" mentions everyone. Only for robot.
+func (t *text) SetMentionedMobileList(mentionedMobileList []string) *text {
+	t.mentionedMobileList = mentionedMobileList
+	return t
+}
+
+// Validate method checks the text message against the WeCom limits.
+func (t *text) Validate() error {
+	return t.build().validate()
+}
+
 // ToJson method return text message string.
-func (t *text) ToJson() string {
+func (t *text) ToJson() (string, error) {
 	return t.build().toJson()
 }
 
 // Send method does Send text message.
 func (t *text) Send() (*RespMessage, error) {
-	return t.build().send()
+	return t.build().send(sendOptions{})
 }
 
 // image struct is used to compose image message push from message client.
 type image struct {
 	message *Message
 	mediaId string
+	data    []byte
 	safe    int
 }
 
@@ -52,6 +83,7 @@ func (i *image) build() *Message {
 	msg := i.message.clone()
 	msg.msgType = "image"
 	msg.mediaId = i.mediaId
+	msg.imageData = i.data
 	msg.safe = i.safe
 	return msg
 }
@@ -62,14 +94,19 @@ func (i *image) SetSafe(safe int) *image {
 	return i
 }
 
+// Validate method checks the image message against the WeCom limits.
+func (i *image) Validate() error {
+	return i.build().validate()
+}
+
 // ToJson method return image message string.
-func (i *image) ToJson() string {
+func (i *image) ToJson() (string, error) {
 	return i.build().toJson()
 }
 
 // Send method does sendWithRetry image message.
 func (i *image) Send() (*RespMessage, error) {
-	return i.build().send()
+	return i.build().send(sendOptions{})
 }
 
 // voice struct is used to compose voice message push from message client.
@@ -86,14 +123,19 @@ func (v *voice) build() *Message {
 	return msg
 }
 
+// Validate method checks the voice message against the WeCom limits.
+func (v *voice) Validate() error {
+	return v.build().validate()
+}
+
 // ToJson method return voice message string.
-func (v *voice) ToJson() string {
+func (v *voice) ToJson() (string, error) {
 	return v.build().toJson()
 }
 
 // Send method does Send voice message.
 func (v *voice) Send() (*RespMessage, error) {
-	return v.build().send()
+	return v.build().send(sendOptions{})
 }
 
 // video struct is used to compose video message push from message client.
@@ -134,14 +176,19 @@ func (v *video) SetSafe(safe int) *video {
 	return v
 }
 
+// Validate method checks the video message against the WeCom limits.
+func (v *video) Validate() error {
+	return v.build().validate()
+}
+
 // ToJson method return video message string.
-func (v *video) ToJson() string {
+func (v *video) ToJson() (string, error) {
 	return v.build().toJson()
 }
 
 // Send method does sendWithRetry video message.
 func (v *video) Send() (*RespMessage, error) {
-	return v.build().send()
+	return v.build().send(sendOptions{})
 }
 
 // file struct is used to compose file message push from message client.
@@ -166,14 +213,19 @@ func (f *file) SetSafe(safe int) *file {
 	return f
 }
 
+// Validate method checks the file message against the WeCom limits.
+func (f *file) Validate() error {
+	return f.build().validate()
+}
+
 // ToJson method return file message string.
-func (f *file) ToJson() string {
+func (f *file) ToJson() (string, error) {
 	return f.build().toJson()
 }
 
 // Send method does sendWithRetry file message.
 func (f *file) Send() (*RespMessage, error) {
-	return f.build().send()
+	return f.build().send(sendOptions{})
 }
 
 // textcard struct is used to compose textcard message push from message client.wx_message
@@ -210,20 +262,73 @@ func (t *textcard) SetEnableIdTrans(enableIdTrans int) *textcard {
 	return t
 }
 
+// Validate method checks the textcard message against the WeCom limits.
+func (t *textcard) Validate() error {
+	return t.build().validate()
+}
+
 // ToJson method return textcard message string.
-func (t *textcard) ToJson() string {
+func (t *textcard) ToJson() (string, error) {
 	return t.build().toJson()
 }
 
 // Send method does sendWithRetry textcard message.
 func (t *textcard) Send() (*RespMessage, error) {
-	return t.build().send()
+	return t.build().send(sendOptions{})
+}
+
+// NewsArticle struct holds an article of the news message.
+type NewsArticle struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Url         string `json:"url,omitempty"`
+	Picurl      string `json:"picurl,omitempty"`
+	Appid       string `json:"appid,omitempty"`
+	Pagepath    string `json:"pagepath,omitempty"`
+}
+
+// news struct is used to compose news message push from message client.
+type news struct {
+	message       *Message
+	articles      []NewsArticle
+	enableIdTrans int
+}
+
+// build method create the new Message client.
+func (n *news) build() *Message {
+	msg := n.message.clone()
+	msg.msgType = "news"
+	msg.articles = n.articles
+	msg.enableIdTrans = n.enableIdTrans
+	return msg
+}
+
+// SetEnableIdTrans method sets the news message enable id translation.
+func (n *news) SetEnableIdTrans(enableIdTrans int) *news {
+	n.enableIdTrans = enableIdTrans
+	return n
+}
+
+// Validate method checks the news message against the WeCom limits.
+func (n *news) Validate() error {
+	return n.build().validate()
+}
+
+// ToJson method return news message string.
+func (n *news) ToJson() (string, error) {
+	return n.build().toJson()
+}
+
+// Send method does Send news message.
+func (n *news) Send() (*RespMessage, error) {
+	return n.build().send(sendOptions{})
 }
 
 // markdown struct is used to compose markdown message push from message client.
 type markdown struct {
-	message *Message
-	content string
+	message   *Message
+	content   string
+	autoSplit bool
 }
 
 // build method create the new Message client.
@@ -231,15 +336,95 @@ func (m *markdown) build() *Message {
 	msg := m.message.clone()
 	msg.msgType = "markdown"
 	msg.content = m.content
+	msg.autoSplit = m.autoSplit
 	return msg
 }
 
+// SetAutoSplit method splits the content exceeding 2048 bytes into several messages on the line or rune boundaries,
+// without breaking the fenced code blocks and inline constructs. The parts are numbered as "(1/3)" and sent in order.
+func (m *markdown) SetAutoSplit(autoSplit bool) *markdown {
+	m.autoSplit = autoSplit
+	return m
+}
+
+// Validate method checks the markdown message against the WeCom limits.
+func (m *markdown) Validate() error {
+	return m.build().validate()
+}
+
 // ToJson method return markdown message string.
-func (m *markdown) ToJson() string {
+func (m *markdown) ToJson() (string, error) {
 	return m.build().toJson()
 }
 
 // Send method does Send markdown message.
 func (m *markdown) Send() (*RespMessage, error) {
-	return m.build().send()
+	return m.build().send(sendOptions{})
+}
+
+// markdownV2 struct is used to compose markdown_v2 message push from robot.
+type markdownV2 struct {
+	message *Message
+	content string
+}
+
+// build method create the new Message client.
+func (m *markdownV2) build() *Message {
+	msg := m.message.clone()
+	msg.msgType = "markdown_v2"
+	msg.content = m.content
+	return msg
+}
+
+// Validate method checks the markdown_v2 message against the WeCom limits.
+func (m *markdownV2) Validate() error {
+	return m.build().validate()
+}
+
+// ToJson method return markdown_v2 message string.
+func (m *markdownV2) ToJson() (string, error) {
+	return m.build().toJson()
+}
+
+// Send method does Send markdown_v2 message.
+func (m *markdownV2) Send() (*RespMessage, error) {
+	return m.build().send(sendOptions{})
+}
+
+// templateCard struct is used to compose template card message push from message client.
+type templateCard struct {
+	message       *Message
+	card          *TemplateCard
+	enableIdTrans int
+}
+
+// build method create the new Message client.
+func (t *templateCard) build() *Message {
+	msg := t.message.clone()
+	msg.msgType = "template_card"
+	msg.templateCard = t.card
+	msg.enableIdTrans = t.enableIdTrans
+	return msg
+}
+
+// SetEnableIdTrans method sets the template card message enable id translation.
+func (t *templateCard) SetEnableIdTrans(enableIdTrans int) *templateCard {
+	t.enableIdTrans = enableIdTrans
+	return t
+}
+
+// Validate method checks the template card message against the WeCom limits.
+func (t *templateCard) Validate() error {
+	return t.build().validate()
+}
+
+// ToJson method return template card message string.
+func (t *templateCard) ToJson() (string, error) {
+	return t.build().toJson()
+}
+
+// Send method does Send template card message.
+// The response code of the interaction card is returned in RespMessage.ResponseCode.
+func (t *templateCard) Send() (*RespMessage, error) {
+	return t.build().send(sendOptions{})
 }
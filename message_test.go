@@ -1,6 +1,7 @@
 package wxcom_test
 
 import (
+	"fmt"
 	"github.com/mingzaily/go-wxcom"
 	"testing"
 )
@@ -15,9 +16,10 @@ func TestMessage_NotToPeople(t *testing.T) {
 		err.Error(),
 		"toUser, toParty, toTag cannot be empty at the same time")
 
+	_, err = m.ToJson()
 	assertEqual(t,
-		m.ToJson(),
-		"")
+		err.Error(),
+		"toUser, toParty, toTag cannot be empty at the same time")
 }
 
 func TestMessage_Send(t *testing.T) {
@@ -35,62 +37,85 @@ func TestMessage_Send(t *testing.T) {
 	assertEqual(t, resp.Msgid, "msgid")
 }
 
+func TestMessage_SendInBatches(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	var users, parties []string
+	for i := 0; i < 2500; i++ {
+		users = append(users, fmt.Sprintf("user%d", i))
+	}
+	for i := 0; i < 150; i++ {
+		parties = append(parties, fmt.Sprintf("%d", i))
+	}
+
+	resp, err := tempWx.NewMessage().ToUser(users).ToParty(parties).Text("测试TEXT").Send()
+
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+	assertEqual(t, resp.Msgid, "msgid")
+	assertEqual(t, resp.Msgids, []string{"msgid", "msgid", "msgid"})
+
+	resp, err = tempWx.NewMessage().ToUser(users).Concurrency(3).Text("测试TEXT").Send()
+
+	assertEqual(t, err, nil)
+	assertEqual(t, len(resp.Msgids), 3)
+}
+
 func TestMessage_ToUser(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"user"}).Text("测试TEXT")
 
-	assertEqual(t, m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"text\",\"safe\":0,\"text\":{\"content\":\"测试TEXT\"},\"touser\":\"user\"}")
 }
 
 func TestMessage_ToParty(t *testing.T) {
 	m := msg.Clone().ToParty([]string{"party"}).Text("测试TEXT")
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"text\",\"safe\":0,\"text\":{\"content\":\"测试TEXT\"},\"toparty\":\"party\"}")
 }
 
 func TestMessage_ToTag(t *testing.T) {
 	m := msg.Clone().ToTag([]string{"tag"}).Text("测试TEXT")
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"text\",\"safe\":0,\"text\":{\"content\":\"测试TEXT\"},\"totag\":\"tag\"}")
 }
 
 func TestMessage_DuplicateCheck(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"user"}).DuplicateCheck(0, 1800).Text("测试TEXT")
 
-	assertEqual(t, m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"text\",\"safe\":0,\"text\":{\"content\":\"测试TEXT\"},\"touser\":\"user\"}")
 
 	m = msg.Clone().ToUser([]string{"user"}).DuplicateCheck(1, 1800).Text("测试TEXT")
 
-	assertEqual(t, m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"duplicate_check_interval\":1800,\"enable_duplicate_check\":1,\"enable_id_trans\":0,\"msgtype\":\"text\",\"safe\":0,\"text\":{\"content\":\"测试TEXT\"},\"touser\":\"user\"}")
 }
 
 func TestMessage_Text(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"test"}).Text("测试TEXT")
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"text\",\"safe\":0,\"text\":{\"content\":\"测试TEXT\"},\"touser\":\"test\"}")
 }
 
 func TestMessage_Text_SetSafe(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"test"}).Text("测试TEXT").SetSafe(1)
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"text\",\"safe\":1,\"text\":{\"content\":\"测试TEXT\"},\"touser\":\"test\"}")
 }
 
 func TestMessage_Text_SetEnableIdTrans(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"test"}).Text("测试TEXT").SetEnableIdTrans(1)
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"enable_id_trans\":1,\"msgtype\":\"text\",\"safe\":0,\"text\":{\"content\":\"测试TEXT\"},\"touser\":\"test\"}")
 }
 
@@ -103,103 +128,102 @@ func TestMessage_Clone(t *testing.T) {
 func TestMessage_Image(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"test"}).Image("cac8d983-5d58-4603-9671-adb3edf2edf3")
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"image\":{\"media_id\":\"cac8d983-5d58-4603-9671-adb3edf2edf3\"},\"msgtype\":\"image\",\"safe\":0,\"touser\":\"test\"}")
 }
 
 func TestMessage_Image_SetSafe(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"test"}).Image("cac8d983-5d58-4603-9671-adb3edf2edf3").SetSafe(1)
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"image\":{\"media_id\":\"cac8d983-5d58-4603-9671-adb3edf2edf3\"},\"msgtype\":\"image\",\"safe\":1,\"touser\":\"test\"}")
 }
 
 func TestMessage_Voice(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"test"}).Voice("cac8d983-5d58-4603-9671-adb3edf2edf3")
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"msgtype\":\"voice\",\"touser\":\"test\",\"voice\":{\"media_id\":\"cac8d983-5d58-4603-9671-adb3edf2edf3\"}}")
 }
 
 func TestMessage_Video(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"test"}).Video("cac8d983-5d58-4603-9671-adb3edf2edf3")
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"msgtype\":\"video\",\"safe\":0,\"touser\":\"test\",\"video\":{\"description\":\"\",\"media_id\":\"cac8d983-5d58-4603-9671-adb3edf2edf3\",\"title\":\"\"}}")
 }
 
 func TestMessage_Video_SetSafe(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"test"}).Video("cac8d983-5d58-4603-9671-adb3edf2edf3").SetSafe(1)
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"msgtype\":\"video\",\"safe\":1,\"touser\":\"test\",\"video\":{\"description\":\"\",\"media_id\":\"cac8d983-5d58-4603-9671-adb3edf2edf3\",\"title\":\"\"}}")
 }
 
 func TestMessage_Video_SetTitle(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"test"}).Video("cac8d983-5d58-4603-9671-adb3edf2edf3").SetTitle("标题")
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"msgtype\":\"video\",\"safe\":0,\"touser\":\"test\",\"video\":{\"description\":\"\",\"media_id\":\"cac8d983-5d58-4603-9671-adb3edf2edf3\",\"title\":\"标题\"}}")
 }
 
 func TestMessage_Video_SetDescription(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"test"}).Video("cac8d983-5d58-4603-9671-adb3edf2edf3").SetDescription("描述")
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"msgtype\":\"video\",\"safe\":0,\"touser\":\"test\",\"video\":{\"description\":\"描述\",\"media_id\":\"cac8d983-5d58-4603-9671-adb3edf2edf3\",\"title\":\"\"}}")
 }
 
 func TestMessage_File(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"test"}).File("cac8d983-5d58-4603-9671-adb3edf2edf3")
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"file\":{\"media_id\":\"cac8d983-5d58-4603-9671-adb3edf2edf3\"},\"msgtype\":\"file\",\"safe\":0,\"touser\":\"test\"}")
 }
 
 func TestMessage_File_SetSafe(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"test"}).File("cac8d983-5d58-4603-9671-adb3edf2edf3").SetSafe(1)
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"file\":{\"media_id\":\"cac8d983-5d58-4603-9671-adb3edf2edf3\"},\"msgtype\":\"file\",\"safe\":1,\"touser\":\"test\"}")
 }
 
 func TestMessage_Textcard(t *testing.T) {
-	m := msg.Clone().ToUser([]string{"test"}).Textcard("标题", "描述", "url")
+	m := msg.Clone().ToUser([]string{"test"}).Textcard("标题", "描述", "https://test.com")
 
-	assertEqual(t,
-		m.ToJson(),
-		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"textcard\",\"textcard\":{\"btntxt\":\"\",\"description\":\"描述\",\"title\":\"标题\",\"url\":\"url\"},\"touser\":\"test\"}")
+	assertJson(t, m,
+		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"textcard\",\"textcard\":{\"btntxt\":\"\",\"description\":\"描述\",\"title\":\"标题\",\"url\":\"https://test.com\"},\"touser\":\"test\"}")
 }
 
 func TestMessage_Textcard_SetBtnTxt(t *testing.T) {
-	m := msg.Clone().ToUser([]string{"test"}).Textcard("标题", "描述", "url").SetBtnTxt("按钮")
+	m := msg.Clone().ToUser([]string{"test"}).Textcard("标题", "描述", "https://test.com").SetBtnTxt("按钮")
 
-	assertEqual(t,
-		m.ToJson(),
-		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"textcard\",\"textcard\":{\"btntxt\":\"按钮\",\"description\":\"描述\",\"title\":\"标题\",\"url\":\"url\"},\"touser\":\"test\"}")
+	assertJson(t, m,
+		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"textcard\",\"textcard\":{\"btntxt\":\"按钮\",\"description\":\"描述\",\"title\":\"标题\",\"url\":\"https://test.com\"},\"touser\":\"test\"}")
 }
 
 func TestMessage_Textcard_SetEnableIdTrans(t *testing.T) {
-	m := msg.Clone().ToUser([]string{"test"}).Textcard("标题", "描述", "url").SetEnableIdTrans(1)
+	m := msg.Clone().ToUser([]string{"test"}).Textcard("标题", "描述", "https://test.com").SetEnableIdTrans(1)
 
-	assertEqual(t,
-		m.ToJson(),
-		"{\"agentid\":123,\"enable_id_trans\":1,\"msgtype\":\"textcard\",\"textcard\":{\"btntxt\":\"\",\"description\":\"描述\",\"title\":\"标题\",\"url\":\"url\"},\"touser\":\"test\"}")
+	assertJson(t, m,
+		"{\"agentid\":123,\"enable_id_trans\":1,\"msgtype\":\"textcard\",\"textcard\":{\"btntxt\":\"\",\"description\":\"描述\",\"title\":\"标题\",\"url\":\"https://test.com\"},\"touser\":\"test\"}")
 }
 
 func TestMessage_Markdown(t *testing.T) {
 	m := msg.Clone().ToUser([]string{"test"}).Markdown("您的会议室已经预定")
 
-	assertEqual(t,
-		m.ToJson(),
+	assertJson(t, m,
 		"{\"agentid\":123,\"markdown\":{\"content\":\"您的会议室已经预定\"},\"msgtype\":\"markdown\",\"touser\":\"test\"}")
 }
+
+func TestMessage_News(t *testing.T) {
+	m := msg.Clone().ToUser([]string{"test"}).News([]wxcom.NewsArticle{
+		{Title: "标题", Description: "描述", Url: "https://test.com", Picurl: "https://test.com/a.png"},
+	})
+
+	assertJson(t, m,
+		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"news\",\"news\":{\"articles\":[{\"title\":\"标题\",\"description\":\"描述\",\"url\":\"https://test.com\",\"picurl\":\"https://test.com/a.png\"}]},\"touser\":\"test\"}")
+
+	_, err := msg.Clone().ToUser([]string{"test"}).News(nil).ToJson()
+	assertEqual(t, err.Error(), "news.articles must have 1 to 8 articles")
+}
@@ -0,0 +1,680 @@
+package wxcom
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// OutboxRecord struct holds a message persisted in the outbox.
+type OutboxRecord struct {
+	Id             string          `json:"id"`
+	Payload        *MessagePayload `json:"payload"`
+	CorrelationId  string          `json:"correlation_id,omitempty"`
+	AutoSplit      bool            `json:"auto_split,omitempty"`
+	IdempotencyKey string          `json:"idempotency_key,omitempty"`
+	// NextPart is the index of the first part not delivered to all the recipients when the content is auto split.
+	NextPart int `json:"next_part,omitempty"`
+	// PartRecipients holds the recipients not delivered the part of NextPart yet, nil if none of them is delivered.
+	PartRecipients *Recipients `json:"part_recipients,omitempty"`
+	Attempts       int         `json:"attempts"`
+	LastError      string      `json:"last_error,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+// OutboxStore interface persists the outbox records until they are delivered or dead.
+type OutboxStore interface {
+	// Put adds or updates the record.
+	Put(record *OutboxRecord) error
+	// Remove removes the record.
+	Remove(id string) error
+	// Pending returns the records not removed, in the order they are added.
+	Pending() ([]*OutboxRecord, error)
+}
+
+// Outbox struct is used to deliver messages asynchronously.
+//
+// The messages are persisted in the store before delivered,
+// so that the pending messages are delivered again after the process restarts.
+// The message whose dedup key is pending is retried after the max backoff without counting the attempts,
+// until the key is done, released or expired.
+type Outbox struct {
+	message     *Message
+	store       OutboxStore
+	workers     int
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	deadLetter  func(record *OutboxRecord, err error)
+	onError     func(record *OutboxRecord, err error)
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	ready    []*OutboxRecord
+	inflight int
+	started  bool
+	closing  bool
+	stopped  bool
+	drained  chan struct{}
+}
+
+// NewOutbox method creates a new Outbox instance with the store.
+func (w *Wxcom) NewOutbox(store OutboxStore) *Outbox {
+	o := &Outbox{
+		message:     w.M(),
+		store:       store,
+		workers:     1,
+		maxAttempts: 5,
+		minBackoff:  time.Second,
+		maxBackoff:  time.Minute,
+		drained:     make(chan struct{}),
+	}
+	o.cond = sync.NewCond(&o.mu)
+	return o
+}
+
+// SetWorkers method sets how many messages are delivered at the same time.
+func (o *Outbox) SetWorkers(workers int) *Outbox {
+	o.workers = workers
+	return o
+}
+
+// SetMaxAttempts method sets the max attempts of delivering a message before it is dead.
+func (o *Outbox) SetMaxAttempts(maxAttempts int) *Outbox {
+	o.maxAttempts = maxAttempts
+	return o
+}
+
+// SetBackoff method sets the retry backoff, it doubles from min to max after every failed attempt.
+func (o *Outbox) SetBackoff(min, max time.Duration) *Outbox {
+	o.minBackoff = min
+	o.maxBackoff = max
+	return o
+}
+
+// OnDeadLetter method sets the callback of the messages failed permanently.
+func (o *Outbox) OnDeadLetter(fn func(record *OutboxRecord, err error)) *Outbox {
+	o.deadLetter = fn
+	return o
+}
+
+// OnError method sets the callback of the store errors when delivering,
+// the record may be delivered again after the process restarts.
+func (o *Outbox) OnError(fn func(record *OutboxRecord, err error)) *Outbox {
+	o.onError = fn
+	return o
+}
+
+// Start method loads the pending messages from the store and starts delivering.
+func (o *Outbox) Start() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.started {
+		return errors.New("outbox is already started")
+	}
+
+	// the store is read under the lock, so that the records enqueued meanwhile are not lost
+	records, err := o.store.Pending()
+	if err != nil {
+		return err
+	}
+	o.started = true
+
+	// the records enqueued before start are already in the store
+	o.inflight -= len(o.ready)
+	o.ready = nil
+	for _, record := range records {
+		o.push(record)
+	}
+
+	workers := o.workers
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go o.work()
+	}
+
+	return nil
+}
+
+// Enqueue method validates and persists the message, it is delivered asynchronously.
+// The id of the outbox record is returned.
+func (o *Outbox) Enqueue(s Sendable) (string, error) {
+	msg, err := buildMessage(o.message, s)
+	if err != nil {
+		return "", err
+	}
+
+	return o.enqueue(msg)
+}
+
+// enqueue method validates and persists the message client.
+func (o *Outbox) enqueue(msg *Message) (string, error) {
+	err := msg.validate()
+	if err != nil {
+		return "", err
+	}
+
+	err = msg.persistable()
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := msg.payload()
+	if err != nil {
+		return "", err
+	}
+
+	id, err := newRandomId()
+	if err != nil {
+		return "", err
+	}
+
+	record := &OutboxRecord{
+		Id:             id,
+		Payload:        payload,
+		CorrelationId:  msg.correlationId,
+		AutoSplit:      msg.autoSplit,
+		IdempotencyKey: msg.idempotencyKey,
+		CreatedAt:      time.Now(),
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closing {
+		return "", errors.New("outbox is closed")
+	}
+
+	err = o.store.Put(record)
+	if err != nil {
+		return "", err
+	}
+	o.push(record)
+
+	return id, nil
+}
+
+// Close method stops accepting messages and waits for the pending messages to be delivered.
+// If the context is done before, the workers are stopped and the undelivered messages are kept in the store.
+func (o *Outbox) Close(ctx context.Context) error {
+	o.mu.Lock()
+	o.closing = true
+	o.checkDrained()
+	o.mu.Unlock()
+
+	select {
+	case <-o.drained:
+		o.stop()
+		return nil
+	case <-ctx.Done():
+		o.stop()
+		return ctx.Err()
+	}
+}
+
+// stop method stops the workers.
+func (o *Outbox) stop() {
+	o.mu.Lock()
+	o.stopped = true
+	o.cond.Broadcast()
+	o.mu.Unlock()
+}
+
+// push method adds the record to the ready queue, the lock must be held.
+func (o *Outbox) push(record *OutboxRecord) {
+	o.ready = append(o.ready, record)
+	o.inflight++
+	o.cond.Signal()
+}
+
+// finish method marks a record is finished, the lock must be held.
+func (o *Outbox) finish() {
+	o.inflight--
+	o.checkDrained()
+}
+
+// checkDrained method closes the drained channel if there is nothing to deliver when closing, the lock must be held.
+func (o *Outbox) checkDrained() {
+	if o.closing && (o.inflight == 0 || !o.started) {
+		select {
+		case <-o.drained:
+		default:
+			close(o.drained)
+		}
+	}
+}
+
+// work method delivers the ready records until the outbox is stopped.
+func (o *Outbox) work() {
+	for {
+		o.mu.Lock()
+		for len(o.ready) == 0 && !o.stopped {
+			o.cond.Wait()
+		}
+		if o.stopped {
+			o.mu.Unlock()
+			return
+		}
+		record := o.ready[0]
+		o.ready = o.ready[1:]
+		o.mu.Unlock()
+
+		o.deliver(record)
+	}
+}
+
+// deliver method sends the record, and retries or reports the dead letter if failed.
+func (o *Outbox) deliver(record *OutboxRecord) {
+	err := o.send(record)
+
+	if err == nil {
+		o.storeError(record, o.store.Remove(record.Id))
+		o.mu.Lock()
+		o.finish()
+		o.mu.Unlock()
+		return
+	}
+
+	var duplicateErr *DuplicateError
+	if errors.As(err, &duplicateErr) && duplicateErr.Pending {
+		// the key is pending until the other send finishes or the key expires, which is not counted as an attempt
+		record.LastError = err.Error()
+		o.retry(record, o.maxBackoff)
+		return
+	}
+
+	record.Attempts++
+	record.LastError = err.Error()
+
+	if !isRetryable(err) || record.Attempts >= o.maxAttempts {
+		o.storeError(record, o.store.Remove(record.Id))
+		if o.deadLetter != nil {
+			o.deadLetter(record, err)
+		}
+		o.mu.Lock()
+		o.finish()
+		o.mu.Unlock()
+		return
+	}
+
+	o.retry(record, o.backoff(record.Attempts))
+}
+
+// retry method persists the record and delivers it again after the delay.
+func (o *Outbox) retry(record *OutboxRecord, delay time.Duration) {
+	o.storeError(record, o.store.Put(record))
+	time.AfterFunc(delay, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		if o.stopped {
+			return
+		}
+		o.ready = append(o.ready, record)
+		o.cond.Signal()
+	})
+}
+
+// storeError method reports the error of the store to the callback.
+func (o *Outbox) storeError(record *OutboxRecord, err error) {
+	if err != nil && o.onError != nil {
+		o.onError(record, err)
+	}
+}
+
+// send method reconstructs the message of the record and sends it.
+// If some parts or batches have been delivered, the record keeps the progress, so that they are not sent again.
+func (o *Outbox) send(record *OutboxRecord) error {
+	s, err := o.message.FromPayload(record.Payload)
+	if err != nil {
+		return &permanentError{err: err}
+	}
+
+	msg := s.(builder).build().CorrelationId(record.CorrelationId)
+	msg.autoSplit = record.AutoSplit
+	msg.idempotencyKey = record.IdempotencyKey
+	opts := sendOptions{nextPart: record.NextPart, partRecipients: record.PartRecipients}
+	if record.NextPart != 0 || record.PartRecipients != nil {
+		// the key is done by the partly delivered attempt, the rest is sent under the key of the attempt
+		opts.dedupSuffix = fmt.Sprintf("#%d", record.Attempts)
+	}
+	resp, err := msg.send(opts)
+	var duplicateErr *DuplicateError
+	if errors.As(err, &duplicateErr) && !duplicateErr.Pending {
+		// sent by another replica or a previous attempt
+		return nil
+	}
+	if err == nil {
+		err = resp.err()
+	}
+	if err != nil && resp != nil && resp.partlyDelivered() {
+		retryProgress(record, resp)
+	}
+
+	return err
+}
+
+// retryProgress method records the part failed and its failed recipients,
+// so that the delivered parts and recipients do not get the message twice.
+func retryProgress(record *OutboxRecord, resp *RespMessage) {
+	if resp.nextPart != record.NextPart {
+		record.NextPart = resp.nextPart
+		record.PartRecipients = nil
+	}
+
+	failed := resp.Result().Failed
+	if !failed.Empty() {
+		record.PartRecipients = &failed
+	}
+}
+
+// backoff method returns the delay before the next attempt.
+func (o *Outbox) backoff(attempts int) time.Duration {
+	return backoff(o.minBackoff, o.maxBackoff, attempts)
+}
+
+// backoff method returns the delay doubling from min to max after every failed attempt.
+func backoff(min, max time.Duration, attempts int) time.Duration {
+	delay := min
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// permanentError struct wraps the error which cannot be fixed by retrying.
+type permanentError struct {
+	err error
+}
+
+// Error method implements the error interface.
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap method returns the wrapped error.
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// isRetryable method reports whether the failed send can be retried.
+// The network errors and the WeCom errors of system busy and frequency limit are retryable.
+// The access token errors are retryable only for system busy, frequency limit and expired token(42001),
+// the others such as invalid corpsecret(40001) or corpid(40013) are permanent.
+func isRetryable(err error) bool {
+	var permanentErr *permanentError
+	var validationErr *ValidationError
+	if errors.As(err, &permanentErr) || errors.As(err, &validationErr) {
+		return false
+	}
+
+	var apiErr *ApiError
+	var tokenErr *tokenError
+	if errors.As(err, &tokenErr) {
+		if errors.As(tokenErr.err, &apiErr) {
+			switch apiErr.Errcode {
+			case -1, 45009, 42001:
+				return true
+			default:
+				return false
+			}
+		}
+		return true
+	}
+
+	if errors.As(err, &apiErr) {
+		switch apiErr.Errcode {
+		case -1, 45009, 45033:
+			return true
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// newRandomId method generates a random id.
+func newRandomId() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// memoryOutboxStore struct is the in-memory OutboxStore.
+type memoryOutboxStore struct {
+	mu      sync.Mutex
+	records map[string]*OutboxRecord
+	order   []string
+}
+
+// NewMemoryOutboxStore method creates a new in-memory OutboxStore, the records are lost when the process exits.
+func NewMemoryOutboxStore() OutboxStore {
+	return &memoryOutboxStore{
+		records: make(map[string]*OutboxRecord),
+	}
+}
+
+// Put method adds or updates the record.
+func (s *memoryOutboxStore) Put(record *OutboxRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.records[record.Id]; !found {
+		s.order = append(s.order, record.Id)
+	}
+	copied := *record
+	s.records[record.Id] = &copied
+
+	return nil
+}
+
+// Remove method removes the record.
+func (s *memoryOutboxStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, id)
+
+	return nil
+}
+
+// Pending method returns the records not removed, in the order they are added.
+func (s *memoryOutboxStore) Pending() ([]*OutboxRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []*OutboxRecord
+	var order []string
+	for _, id := range s.order {
+		if record, found := s.records[id]; found {
+			copied := *record
+			records = append(records, &copied)
+			order = append(order, id)
+		}
+	}
+	s.order = order
+
+	return records, nil
+}
+
+// fileOutboxEntry struct holds a line of the append-only outbox file.
+type fileOutboxEntry struct {
+	Op     string        `json:"op"`
+	Id     string        `json:"id,omitempty"`
+	Record *OutboxRecord `json:"record,omitempty"`
+}
+
+// FileOutboxStore struct is the OutboxStore persisted in a local append-only file.
+//
+// Every change is appended as a json line, the file is compacted when opened.
+type FileOutboxStore struct {
+	memory *memoryOutboxStore
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+}
+
+// NewFileOutboxStore method opens or creates the outbox file.
+func NewFileOutboxStore(path string) (*FileOutboxStore, error) {
+	s := &FileOutboxStore{
+		memory: NewMemoryOutboxStore().(*memoryOutboxStore),
+		path:   path,
+	}
+
+	err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.compact()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// load method replays the outbox file.
+func (s *FileOutboxStore) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var corrupted error
+	for line := 1; scanner.Scan(); line++ {
+		if corrupted != nil {
+			// only the last line may be partially written when the process crashed
+			return corrupted
+		}
+		entry := &fileOutboxEntry{}
+		if err = json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			corrupted = fmt.Errorf("outbox file is corrupted at line %d: %w", line, err)
+			continue
+		}
+		switch entry.Op {
+		case "put":
+			_ = s.memory.Put(entry.Record)
+		case "remove":
+			_ = s.memory.Remove(entry.Id)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// compact method rewrites the outbox file with the pending records only.
+func (s *FileOutboxStore) compact() error {
+	records, _ := s.memory.Pending()
+
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(f)
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err = encoder.Encode(&fileOutboxEntry{Op: "put", Record: record}); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+
+	s.file, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0600)
+	return err
+}
+
+// append method appends the entry to the outbox file.
+func (s *FileOutboxStore) append(entry *fileOutboxEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return errors.New("outbox store is closed")
+	}
+	if _, err = s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Put method adds or updates the record.
+func (s *FileOutboxStore) Put(record *OutboxRecord) error {
+	err := s.append(&fileOutboxEntry{Op: "put", Record: record})
+	if err != nil {
+		return err
+	}
+	return s.memory.Put(record)
+}
+
+// Remove method removes the record.
+func (s *FileOutboxStore) Remove(id string) error {
+	err := s.append(&fileOutboxEntry{Op: "remove", Id: id})
+	if err != nil {
+		return err
+	}
+	return s.memory.Remove(id)
+}
+
+// Pending method returns the records not removed, in the order they are added.
+func (s *FileOutboxStore) Pending() ([]*OutboxRecord, error) {
+	return s.memory.Pending()
+}
+
+// Close method closes the outbox file.
+func (s *FileOutboxStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,284 @@
+package wxcom_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/mingzaily/go-wxcom"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOutbox_Deliver(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	store := wxcom.NewMemoryOutboxStore()
+	outbox := tempWx.NewOutbox(store).SetWorkers(2)
+	assertEqual(t, outbox.Start(), nil)
+
+	for i := 0; i < 5; i++ {
+		_, err := outbox.Enqueue(tempWx.M().ToUser([]string{"test"}).Text("测试TEXT"))
+		assertEqual(t, err, nil)
+	}
+
+	_, err := outbox.Enqueue(tempWx.M().Text("测试TEXT"))
+	assertEqual(t, err.Error(), "toUser, toParty, toTag cannot be empty at the same time")
+
+	assertEqual(t, outbox.Close(context.Background()), nil)
+
+	records, _ := store.Pending()
+	assertEqual(t, len(records), 0)
+
+	_, err = outbox.Enqueue(tempWx.M().ToUser([]string{"test"}).Text("测试TEXT"))
+	assertEqual(t, err.Error(), "outbox is closed")
+}
+
+func TestOutbox_DeadLetter(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"access_token\":\"token\",\"expires_in\":7200}"))
+		default:
+			atomic.AddInt32(&calls, 1)
+			_, _ = w.Write([]byte("{\"errcode\":-1,\"errmsg\":\"system busy\"}"))
+		}
+	}))
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	var dead *wxcom.OutboxRecord
+	outbox := tempWx.NewOutbox(wxcom.NewMemoryOutboxStore()).
+		SetMaxAttempts(3).
+		SetBackoff(time.Millisecond, 5*time.Millisecond).
+		OnDeadLetter(func(record *wxcom.OutboxRecord, err error) {
+			dead = record
+		})
+	assertEqual(t, outbox.Start(), nil)
+
+	id, err := outbox.Enqueue(tempWx.M().ToUser([]string{"test"}).Text("测试TEXT"))
+	assertEqual(t, err, nil)
+	assertEqual(t, outbox.Close(context.Background()), nil)
+
+	assertEqual(t, atomic.LoadInt32(&calls), int32(3))
+	assertEqual(t, dead.Id, id)
+	assertEqual(t, dead.Attempts, 3)
+	assertEqual(t, dead.LastError, "wxcom: errcode -1, errmsg system busy")
+}
+
+func TestOutbox_RetryTokenError(t *testing.T) {
+	var tokens, calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			switch atomic.AddInt32(&tokens, 1) {
+			case 1:
+				w.WriteHeader(http.StatusBadGateway)
+			case 2:
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte("{\"errcode\":45009,\"errmsg\":\"api freq out of limit\"}"))
+			default:
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"access_token\":\"token\",\"expires_in\":7200}"))
+			}
+		default:
+			atomic.AddInt32(&calls, 1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"msgid\":\"msgid\"}"))
+		}
+	}))
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	var dead *wxcom.OutboxRecord
+	outbox := tempWx.NewOutbox(wxcom.NewMemoryOutboxStore()).
+		SetBackoff(time.Millisecond, time.Millisecond).
+		OnDeadLetter(func(record *wxcom.OutboxRecord, err error) {
+			dead = record
+		})
+	assertEqual(t, outbox.Start(), nil)
+
+	_, err := outbox.Enqueue(tempWx.M().ToUser([]string{"test"}).Text("测试TEXT"))
+	assertEqual(t, err, nil)
+	assertEqual(t, outbox.Close(context.Background()), nil)
+
+	assertEqual(t, dead == nil, true)
+	assertEqual(t, atomic.LoadInt32(&tokens), int32(3))
+	assertEqual(t, atomic.LoadInt32(&calls), int32(1))
+}
+
+func TestOutbox_PermanentTokenError(t *testing.T) {
+	var tokens int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&tokens, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{\"errcode\":40001,\"errmsg\":\"invalid credential\"}"))
+	}))
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	var dead *wxcom.OutboxRecord
+	outbox := tempWx.NewOutbox(wxcom.NewMemoryOutboxStore()).
+		SetBackoff(time.Millisecond, time.Millisecond).
+		OnDeadLetter(func(record *wxcom.OutboxRecord, err error) {
+			dead = record
+		})
+	assertEqual(t, outbox.Start(), nil)
+
+	// the invalid credential cannot be fixed by retrying
+	_, err := outbox.Enqueue(tempWx.M().ToUser([]string{"test"}).Text("测试TEXT"))
+	assertEqual(t, err, nil)
+	assertEqual(t, outbox.Close(context.Background()), nil)
+
+	assertEqual(t, dead != nil, true)
+	assertEqual(t, dead.Attempts, 1)
+}
+
+func TestOutbox_RetryFailedBatch(t *testing.T) {
+	var firstBatch, failedBatch int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"access_token\":\"token\",\"expires_in\":7200}"))
+		default:
+			body, _ := ioutil.ReadAll(r.Body)
+			if strings.Contains(string(body), "user0|") {
+				atomic.AddInt32(&firstBatch, 1)
+			}
+			if strings.Contains(string(body), "quota_user") && atomic.AddInt32(&failedBatch, 1) == 1 {
+				_, _ = w.Write([]byte("{\"errcode\":45009,\"errmsg\":\"api freq out of limit\"}"))
+				return
+			}
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"msgid\":\"msgid\"}"))
+		}
+	}))
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	var users []string
+	for i := 0; i < 1000; i++ {
+		users = append(users, fmt.Sprintf("user%d", i))
+	}
+	users = append(users, "quota_user")
+
+	outbox := tempWx.NewOutbox(wxcom.NewMemoryOutboxStore()).SetBackoff(time.Millisecond, time.Millisecond)
+	assertEqual(t, outbox.Start(), nil)
+
+	_, err := outbox.Enqueue(tempWx.M().ToUser(users).Text("测试TEXT"))
+	assertEqual(t, err, nil)
+	assertEqual(t, outbox.Close(context.Background()), nil)
+
+	assertEqual(t, atomic.LoadInt32(&firstBatch), int32(1))
+	assertEqual(t, atomic.LoadInt32(&failedBatch), int32(2))
+}
+
+func TestFileOutboxStore_Restart(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	path := filepath.Join(t.TempDir(), "outbox.log")
+
+	// enqueue without delivering, as if the process exits
+	store, err := wxcom.NewFileOutboxStore(path)
+	assertEqual(t, err, nil)
+	outbox := tempWx.NewOutbox(store)
+	_, err = outbox.Enqueue(tempWx.M().ToUser([]string{"test"}).Markdown("测试MARKDOWN"))
+	assertEqual(t, err, nil)
+	assertEqual(t, outbox.Close(context.Background()), nil)
+	assertEqual(t, store.Close(), nil)
+
+	// restart
+	store, err = wxcom.NewFileOutboxStore(path)
+	assertEqual(t, err, nil)
+	records, _ := store.Pending()
+	assertEqual(t, len(records), 1)
+	assertEqual(t, records[0].Payload.Markdown.Content, "测试MARKDOWN")
+
+	outbox = tempWx.NewOutbox(store)
+	assertEqual(t, outbox.Start(), nil)
+	assertEqual(t, outbox.Close(context.Background()), nil)
+	assertEqual(t, store.Close(), nil)
+
+	store, err = wxcom.NewFileOutboxStore(path)
+	assertEqual(t, err, nil)
+	records, _ = store.Pending()
+	assertEqual(t, len(records), 0)
+	assertEqual(t, store.Close(), nil)
+}
+
+func TestOutbox_RetryFailedPart(t *testing.T) {
+	var firstPart, lastPart int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"access_token\":\"token\",\"expires_in\":7200}"))
+		default:
+			body, _ := ioutil.ReadAll(r.Body)
+			if strings.Contains(string(body), "(1/2)") {
+				atomic.AddInt32(&firstPart, 1)
+			}
+			if strings.Contains(string(body), "(2/2)") && atomic.AddInt32(&lastPart, 1) == 1 {
+				_, _ = w.Write([]byte("{\"errcode\":45009,\"errmsg\":\"api freq out of limit\"}"))
+				return
+			}
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"msgid\":\"msgid\"}"))
+		}
+	}))
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	outbox := tempWx.NewOutbox(wxcom.NewMemoryOutboxStore()).SetBackoff(time.Millisecond, time.Millisecond)
+	assertEqual(t, outbox.Start(), nil)
+
+	content := strings.Repeat("测试TEXT\n", 300)
+	_, err := outbox.Enqueue(tempWx.M().ToUser([]string{"a", "b"}).Text(content).SetAutoSplit(true))
+	assertEqual(t, err, nil)
+	assertEqual(t, outbox.Close(context.Background()), nil)
+
+	assertEqual(t, atomic.LoadInt32(&firstPart), int32(1))
+	assertEqual(t, atomic.LoadInt32(&lastPart), int32(2))
+}
+
+func TestFileOutboxStore_Corrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.log")
+
+	record := "{\"op\":\"put\",\"record\":{\"id\":\"1\",\"payload\":{\"touser\":\"test\",\"msgtype\":\"text\",\"text\":{\"content\":\"测试TEXT\"}}}}\n"
+
+	// the partially written last line is skipped
+	assertEqual(t, ioutil.WriteFile(path, []byte(record+"{\"op\":\"put\",\"rec"), 0600), nil)
+	store, err := wxcom.NewFileOutboxStore(path)
+	assertEqual(t, err, nil)
+	records, _ := store.Pending()
+	assertEqual(t, len(records), 1)
+	assertEqual(t, store.Close(), nil)
+
+	// the corrupted line in the middle is reported
+	assertEqual(t, ioutil.WriteFile(path, []byte("{\"op\":\"put\",\"rec\n"+record), 0600), nil)
+	_, err = wxcom.NewFileOutboxStore(path)
+	assertEqual(t, strings.HasPrefix(err.Error(), "outbox file is corrupted at line 1"), true)
+}
@@ -0,0 +1,247 @@
+package wxcom
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// MessagePayload struct holds the request payload of send message.
+// The payload sent to appchat has chatid instead of agentid and recipients,
+// and the payload sent by robot has neither.
+//
+// The fields are in the alphabetical order of the json keys.
+type MessagePayload struct {
+	Agentid                int              `json:"agentid,omitempty"`
+	Chatid                 string           `json:"chatid,omitempty"`
+	DuplicateCheckInterval *int             `json:"duplicate_check_interval,omitempty"`
+	EnableDuplicateCheck   int              `json:"enable_duplicate_check,omitempty"`
+	EnableIdTrans          *int             `json:"enable_id_trans,omitempty"`
+	File                   *MediaPayload    `json:"file,omitempty"`
+	Image                  *MediaPayload    `json:"image,omitempty"`
+	Markdown               *MarkdownPayload `json:"markdown,omitempty"`
+	MarkdownV2             *MarkdownPayload `json:"markdown_v2,omitempty"`
+	Msgtype                string           `json:"msgtype"`
+	News                   *NewsPayload     `json:"news,omitempty"`
+	Safe                   *int             `json:"safe,omitempty"`
+	TemplateCard           *TemplateCard    `json:"template_card,omitempty"`
+	Text                   *TextPayload     `json:"text,omitempty"`
+	Textcard               *TextcardPayload `json:"textcard,omitempty"`
+	Toparty                string           `json:"toparty,omitempty"`
+	Totag                  string           `json:"totag,omitempty"`
+	Touser                 string           `json:"touser,omitempty"`
+	Video                  *VideoPayload    `json:"video,omitempty"`
+	Voice                  *MediaPayload    `json:"voice,omitempty"`
+}
+
+// TextPayload struct holds the payload of text message.
+type TextPayload struct {
+	Content             string   `json:"content"`
+	MentionedList       []string `json:"mentioned_list,omitempty"`
+	MentionedMobileList []string `json:"mentioned_mobile_list,omitempty"`
+}
+
+// MediaPayload struct holds the payload of image, voice and file message.
+// The image sent by robot has base64 and md5 instead of media_id.
+type MediaPayload struct {
+	Base64  string `json:"base64,omitempty"`
+	Md5     string `json:"md5,omitempty"`
+	MediaId string `json:"media_id,omitempty"`
+}
+
+// VideoPayload struct holds the payload of video message.
+type VideoPayload struct {
+	Description string `json:"description"`
+	MediaId     string `json:"media_id"`
+	Title       string `json:"title"`
+}
+
+// TextcardPayload struct holds the payload of textcard message.
+type TextcardPayload struct {
+	Btntxt      string `json:"btntxt"`
+	Description string `json:"description"`
+	Title       string `json:"title"`
+	Url         string `json:"url"`
+}
+
+// NewsPayload struct holds the payload of news message.
+type NewsPayload struct {
+	Articles []NewsArticle `json:"articles"`
+}
+
+// MarkdownPayload struct holds the payload of markdown message.
+type MarkdownPayload struct {
+	Content string `json:"content"`
+}
+
+// payload method generate http request payload.
+func (m *Message) payload() (*MessagePayload, error) {
+	safe, enableIdTrans := m.safe, m.enableIdTrans
+
+	payload := &MessagePayload{
+		Agentid: m.wx.agentid,
+		Msgtype: m.msgType,
+		Touser:  strings.Join(m.toUser, "|"),
+		Toparty: strings.Join(m.toParty, "|"),
+		Totag:   strings.Join(m.toTag, "|"),
+	}
+	if m.enableDuplicateCheck != 0 {
+		duplicateCheckInterval := m.duplicateCheckInterval
+		payload.EnableDuplicateCheck = m.enableDuplicateCheck
+		payload.DuplicateCheckInterval = &duplicateCheckInterval
+	}
+
+	switch m.msgType {
+	case "text":
+		payload.Text = &TextPayload{
+			Content:             m.content,
+			MentionedList:       m.mentionedList,
+			MentionedMobileList: m.mentionedMobileList,
+		}
+		payload.Safe = &safe
+		payload.EnableIdTrans = &enableIdTrans
+	case "image":
+		payload.Image = &MediaPayload{MediaId: m.mediaId}
+		if len(m.imageData) != 0 {
+			sum := md5.Sum(m.imageData)
+			payload.Image = &MediaPayload{Base64: base64.StdEncoding.EncodeToString(m.imageData), Md5: hex.EncodeToString(sum[:])}
+		}
+		payload.Safe = &safe
+	case "voice":
+		payload.Voice = &MediaPayload{MediaId: m.mediaId}
+	case "video":
+		payload.Video = &VideoPayload{MediaId: m.mediaId, Title: m.title, Description: m.description}
+		payload.Safe = &safe
+	case "file":
+		payload.File = &MediaPayload{MediaId: m.mediaId}
+		payload.Safe = &safe
+	case "textcard":
+		payload.Textcard = &TextcardPayload{Title: m.title, Description: m.description, Url: m.url, Btntxt: m.btnTxt}
+		payload.EnableIdTrans = &enableIdTrans
+	case "news":
+		payload.News = &NewsPayload{Articles: m.articles}
+		payload.EnableIdTrans = &enableIdTrans
+	case "markdown":
+		payload.Markdown = &MarkdownPayload{Content: m.content}
+	case "markdown_v2":
+		payload.MarkdownV2 = &MarkdownPayload{Content: m.content}
+	case "template_card":
+		payload.TemplateCard = m.templateCard
+		payload.EnableIdTrans = &enableIdTrans
+	default:
+		return nil, errors.New("unsupported msg type")
+	}
+
+	if m.target.kind == targetAppchat {
+		payload.Agentid = 0
+		payload.Chatid = m.target.chatid
+		payload.EnableIdTrans = nil
+	}
+	if m.target.kind == targetRobot {
+		payload.Agentid = 0
+		payload.Safe = nil
+		payload.EnableIdTrans = nil
+	}
+
+	return payload, nil
+}
+
+// FromJson method reconstructs the message from the string returned by ToJson.
+// The agentid of the current client is used instead of the one in the string.
+// The array returned by ToJson for the message split into parts is not accepted,
+// unmarshal it into []MessagePayload and reconstruct each part by FromPayload instead.
+func (m *Message) FromJson(data string) (Sendable, error) {
+	if strings.HasPrefix(strings.TrimSpace(data), "[") {
+		return nil, errors.New("the split message parts cannot be reconstructed as one message, use FromPayload for each part")
+	}
+
+	payload := &MessagePayload{}
+
+	err := json.Unmarshal([]byte(data), payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.FromPayload(payload)
+}
+
+// FromPayload method reconstructs the message from the payload.
+// The message is sent to the appchat if the payload has chatid.
+// The agentid of the current client is used instead of the one in the payload.
+func (m *Message) FromPayload(payload *MessagePayload) (Sendable, error) {
+	msg := m.clone().
+		ToUser(splitRecipients(payload.Touser)).
+		ToParty(splitRecipients(payload.Toparty)).
+		ToTag(splitRecipients(payload.Totag))
+	if payload.Chatid != "" {
+		msg.target = appchatTarget(payload.Chatid)
+	}
+	if payload.EnableDuplicateCheck != 0 {
+		msg.enableDuplicateCheck = payload.EnableDuplicateCheck
+		if payload.DuplicateCheckInterval != nil {
+			msg.duplicateCheckInterval = *payload.DuplicateCheckInterval
+		}
+	}
+
+	var safe, enableIdTrans int
+	if payload.Safe != nil {
+		safe = *payload.Safe
+	}
+	if payload.EnableIdTrans != nil {
+		enableIdTrans = *payload.EnableIdTrans
+	}
+
+	switch {
+	case payload.Msgtype == "text" && payload.Text != nil:
+		return msg.Text(payload.Text.Content).
+			SetSafe(safe).
+			SetEnableIdTrans(enableIdTrans).
+			SetMentionedList(payload.Text.MentionedList).
+			SetMentionedMobileList(payload.Text.MentionedMobileList), nil
+	case payload.Msgtype == "image" && payload.Image != nil && payload.Image.Base64 != "":
+		data, err := base64.StdEncoding.DecodeString(payload.Image.Base64)
+		if err != nil {
+			return nil, err
+		}
+		return msg.ImageData(data).SetSafe(safe), nil
+	case payload.Msgtype == "image" && payload.Image != nil:
+		return msg.Image(payload.Image.MediaId).SetSafe(safe), nil
+	case payload.Msgtype == "voice" && payload.Voice != nil:
+		return msg.Voice(payload.Voice.MediaId), nil
+	case payload.Msgtype == "video" && payload.Video != nil:
+		return msg.Video(payload.Video.MediaId).
+			SetTitle(payload.Video.Title).
+			SetDescription(payload.Video.Description).
+			SetSafe(safe), nil
+	case payload.Msgtype == "file" && payload.File != nil:
+		return msg.File(payload.File.MediaId).SetSafe(safe), nil
+	case payload.Msgtype == "textcard" && payload.Textcard != nil:
+		return msg.Textcard(payload.Textcard.Title, payload.Textcard.Description, payload.Textcard.Url).
+			SetBtnTxt(payload.Textcard.Btntxt).
+			SetEnableIdTrans(enableIdTrans), nil
+	case payload.Msgtype == "news" && payload.News != nil:
+		return msg.News(payload.News.Articles).SetEnableIdTrans(enableIdTrans), nil
+	case payload.Msgtype == "markdown" && payload.Markdown != nil:
+		return msg.Markdown(payload.Markdown.Content), nil
+	case payload.Msgtype == "markdown_v2" && payload.MarkdownV2 != nil:
+		return msg.MarkdownV2(payload.MarkdownV2.Content), nil
+	case payload.Msgtype == "template_card" && payload.TemplateCard != nil:
+		return msg.TemplateCard(payload.TemplateCard).SetEnableIdTrans(enableIdTrans), nil
+	default:
+		return nil, errors.New("unsupported msg type")
+	}
+}
+
+// persistable method checks the message can be reconstructed from its payload by FromPayload.
+func (m *Message) persistable() error {
+	if m.target.kind == targetRobot {
+		return errors.New("robot message cannot be persisted")
+	}
+	if m.target.kind == targetLinkedcorp {
+		return errors.New("linkedcorp message cannot be persisted")
+	}
+	return nil
+}
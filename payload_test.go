@@ -0,0 +1,55 @@
+package wxcom_test
+
+import (
+	"encoding/json"
+	"github.com/mingzaily/go-wxcom"
+	"testing"
+)
+
+func TestMessage_FromJson(t *testing.T) {
+	m := msg.Clone().ToUser([]string{"a", "b"}).ToParty([]string{"1"}).DuplicateCheck(1, 600)
+
+	messages := []wxcom.Sendable{
+		m.Text("测试TEXT").SetSafe(1).SetEnableIdTrans(1),
+		m.Image("media").SetSafe(1),
+		m.Voice("media"),
+		m.Video("media").SetTitle("标题").SetDescription("描述"),
+		m.File("media"),
+		m.Textcard("标题", "描述", "https://test.com").SetBtnTxt("按钮"),
+		m.News([]wxcom.NewsArticle{{Title: "标题", Url: "https://test.com"}}),
+		m.Markdown("测试MARKDOWN"),
+		m.TemplateCard(&wxcom.TemplateCard{CardType: "text_notice", MainTitle: &wxcom.CardMainTitle{Title: "标题"}}),
+	}
+
+	for _, message := range messages {
+		data, err := message.ToJson()
+		assertEqual(t, err, nil)
+
+		reconstructed, err := msg.Clone().FromJson(data)
+		assertEqual(t, err, nil)
+		assertJson(t, reconstructed, data)
+	}
+}
+
+func TestMessage_FromJson_Unsupported(t *testing.T) {
+	_, err := msg.Clone().FromJson("{\"msgtype\":\"music\"}")
+	assertEqual(t, err.Error(), "unsupported msg type")
+
+	_, err = msg.Clone().FromJson("{")
+	assertNotEqual(t, err, nil)
+
+	_, err = msg.Clone().FromJson("[{\"msgtype\":\"text\"},{\"msgtype\":\"text\"}]")
+	assertEqual(t, err.Error(), "the split message parts cannot be reconstructed as one message, use FromPayload for each part")
+}
+
+func TestMessagePayload_Unmarshal(t *testing.T) {
+	payload := &wxcom.MessagePayload{}
+	err := json.Unmarshal([]byte("{\"agentid\":123,\"msgtype\":\"markdown\",\"markdown\":{\"content\":\"测试\"},\"touser\":\"test\"}"), payload)
+
+	assertEqual(t, err, nil)
+	assertEqual(t, payload.Markdown.Content, "测试")
+
+	m, err := msg.Clone().FromPayload(payload)
+	assertEqual(t, err, nil)
+	assertJson(t, m, "{\"agentid\":123,\"markdown\":{\"content\":\"测试\"},\"msgtype\":\"markdown\",\"touser\":\"test\"}")
+}
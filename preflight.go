@@ -0,0 +1,241 @@
+package wxcom
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// allRecipient is the userid sending to all the members in the visible scope of the agent.
+const allRecipient = "@all"
+
+// PreflightReport struct holds the recipients outside the visible scope of the agent.
+type PreflightReport struct {
+	OutOfScope Recipients
+}
+
+// InScope method reports whether all the recipients are in the visible scope.
+func (r *PreflightReport) InScope() bool {
+	return r.OutOfScope.Empty()
+}
+
+// ScopeError struct is returned by Send with StrictScope when some of the recipients are outside the visible scope.
+type ScopeError struct {
+	Report *PreflightReport
+}
+
+// Error method implements the error interface.
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("recipients out of the agent scope: users [%s], parties [%s], tags [%s]",
+		strings.Join(e.Report.OutOfScope.Users, "|"),
+		strings.Join(e.Report.OutOfScope.Parties, "|"),
+		strings.Join(e.Report.OutOfScope.Tags, "|"))
+}
+
+// ToAll method sends the message to all the members in the visible scope of the agent.
+// The parties and tags are ignored by WeCom when sending to all.
+func (m *Message) ToAll() *Message {
+	m.toUser = []string{allRecipient}
+	return m
+}
+
+// StrictScope method makes Send run Preflight first, and return a *ScopeError without sending
+// when some of the recipients are outside the visible scope of the agent.
+func (m *Message) StrictScope() *Message {
+	m.strictScope = true
+	return m
+}
+
+// Preflight method checks the recipients against the visible scope of the agent got by `agent/get`.
+//
+// A user is in scope if it is allowed directly, belongs to an allowed party or its sub party, or has an allowed tag.
+// A party is in scope if it is allowed or a sub party of an allowed party, the parties of the allowed tags are allowed.
+// A tag is in scope if it is allowed.
+// The user not allowed directly is got by `user/get`, one call per uncached user, up to 1000 calls per message.
+// The agent scope, departments and users are cached for 5 minutes.
+func (m *Message) Preflight() (*PreflightReport, error) {
+	if m.target.kind == targetRobot || m.target.kind == targetLinkedcorp {
+		return nil, errors.New("preflight is only supported by the message sent to the members of the corp")
+	}
+
+	report := &PreflightReport{}
+	if len(m.toUser) == 1 && m.toUser[0] == allRecipient {
+		return report, nil
+	}
+
+	agent, err := m.wx.cachedAgent()
+	if err != nil {
+		return nil, err
+	}
+
+	scope := &agentScope{
+		wx:      m.wx,
+		users:   make(map[string]struct{}),
+		parties: make(map[int]struct{}),
+		tags:    make(map[string]struct{}),
+	}
+	for _, user := range agent.AllowUserinfos.User {
+		scope.users[user.Userid] = struct{}{}
+	}
+	for _, party := range agent.AllowPartys.Partyid {
+		scope.parties[party] = struct{}{}
+	}
+	for _, tag := range agent.AllowTags.Tagid {
+		scope.tags[strconv.Itoa(tag)] = struct{}{}
+		scope.tagIds = append(scope.tagIds, tag)
+	}
+
+	// the parties of the allowed tags are allowed too
+	if len(scope.tagIds) != 0 && (len(m.toParty) != 0 || len(m.toUser) != 0) {
+		err = scope.loadTagUsers()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, party := range m.toParty {
+		id, err := strconv.Atoi(party)
+		if err != nil {
+			report.OutOfScope.Parties = append(report.OutOfScope.Parties, party)
+			continue
+		}
+		in, err := scope.partyInScope(id)
+		if err != nil {
+			return nil, err
+		}
+		if !in {
+			report.OutOfScope.Parties = append(report.OutOfScope.Parties, party)
+		}
+	}
+
+	for _, tag := range m.toTag {
+		if _, found := scope.tags[tag]; !found {
+			report.OutOfScope.Tags = append(report.OutOfScope.Tags, tag)
+		}
+	}
+
+	for _, user := range m.toUser {
+		in, err := scope.userInScope(user)
+		if err != nil {
+			return nil, err
+		}
+		if !in {
+			report.OutOfScope.Users = append(report.OutOfScope.Users, user)
+		}
+	}
+
+	return report, nil
+}
+
+// agentScope struct holds the visible scope of the agent, the departments are fetched lazily.
+type agentScope struct {
+	wx            *Wxcom
+	users         map[string]struct{}
+	parties       map[int]struct{}
+	tags          map[string]struct{}
+	tagIds        []int
+	parents       map[int]int
+	tagUsers      map[string]struct{}
+	tagParties    map[int]struct{}
+	tagUsersReady bool
+}
+
+// partyInScope method checks the party or its ancestors are allowed directly or by tag.
+// The tag members must be loaded before if there are allowed tags.
+func (s *agentScope) partyInScope(id int) (bool, error) {
+	if len(s.parties) == 0 && len(s.tagParties) == 0 {
+		return false, nil
+	}
+
+	if s.parents == nil {
+		departments, err := s.wx.cachedDepartments()
+		if err != nil {
+			return false, err
+		}
+		s.parents = make(map[int]int, len(departments))
+		for _, department := range departments {
+			s.parents[department.Id] = department.Parentid
+		}
+	}
+
+	for seen := 0; seen <= len(s.parents); seen++ {
+		if _, found := s.parties[id]; found {
+			return true, nil
+		}
+		if _, found := s.tagParties[id]; found {
+			return true, nil
+		}
+		parent, found := s.parents[id]
+		if !found || parent == 0 || parent == id {
+			return false, nil
+		}
+		id = parent
+	}
+
+	return false, nil
+}
+
+// userInScope method checks the user is allowed directly, by party or by tag.
+func (s *agentScope) userInScope(userid string) (bool, error) {
+	if _, found := s.users[userid]; found {
+		return true, nil
+	}
+
+	if _, found := s.tagUsers[userid]; found {
+		return true, nil
+	}
+
+	if len(s.parties) == 0 && len(s.tagParties) == 0 {
+		return false, nil
+	}
+
+	user, err := s.wx.cachedUser(userid)
+	if err != nil {
+		return false, err
+	}
+	if user.Errcode != 0 {
+		// the user does not exist
+		return false, nil
+	}
+
+	for _, department := range user.Department {
+		in, err := s.partyInScope(department)
+		if err != nil {
+			return false, err
+		}
+		if in {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// loadTagUsers method fetches the members of the allowed tags.
+func (s *agentScope) loadTagUsers() error {
+	if s.tagUsersReady {
+		return nil
+	}
+
+	s.tagUsers = make(map[string]struct{})
+	s.tagParties = make(map[int]struct{})
+	for _, tagid := range s.tagIds {
+		tag, err := s.wx.GetTag(tagid)
+		if err != nil {
+			return err
+		}
+		if tag.Errcode != 0 {
+			return errors.New(tag.Errmsg)
+		}
+		for _, user := range tag.Userlist {
+			s.tagUsers[user.Userid] = struct{}{}
+		}
+		for _, party := range tag.Partylist {
+			s.tagParties[party] = struct{}{}
+		}
+	}
+	s.tagUsersReady = true
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package wxcom_test
+
+import (
+	"errors"
+	"github.com/mingzaily/go-wxcom"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMessage_ToAll(t *testing.T) {
+	msg := wx.M().ToAll()
+
+	assertJson(t, msg.Text("content"), "{\"agentid\":1,\"enable_id_trans\":0,\"msgtype\":\"text\",\"safe\":0,\"text\":{\"content\":\"content\"},\"touser\":\"@all\"}")
+
+	err := msg.Clone().ToUser([]string{"@all", "user"}).Text("content").Validate()
+	assertNotEqual(t, err, nil)
+	assertEqual(t, strings.Contains(err.Error(), "@all"), true)
+}
+
+func TestMessage_Preflight(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	report, err := tempWx.M().
+		ToUser([]string{"allowed_user", "party_user", "tag_user", "other_user", "unknown_user", "private_user"}).
+		ToParty([]string{"2", "4", "5"}).
+		ToTag([]string{"3", "6"}).
+		Preflight()
+	assertEqual(t, err, nil)
+	assertEqual(t, report.InScope(), false)
+	assertEqual(t, report.OutOfScope.Users, []string{"other_user", "unknown_user", "private_user"})
+	assertEqual(t, report.OutOfScope.Parties, []string{"5"})
+	assertEqual(t, report.OutOfScope.Tags, []string{"6"})
+
+	report, err = tempWx.M().ToUser([]string{"allowed_user"}).ToParty([]string{"4"}).Preflight()
+	assertEqual(t, err, nil)
+	assertEqual(t, report.InScope(), true)
+
+	report, err = tempWx.M().ToAll().Preflight()
+	assertEqual(t, err, nil)
+	assertEqual(t, report.InScope(), true)
+}
+
+func TestMessage_StrictScope(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.M().ToUser([]string{"allowed_user", "other_user"}).StrictScope().Text("content").Send()
+	assertEqual(t, resp == nil, true)
+
+	var scopeErr *wxcom.ScopeError
+	assertEqual(t, errors.As(err, &scopeErr), true)
+	assertEqual(t, scopeErr.Report.OutOfScope.Users, []string{"other_user"})
+}
+
+func TestMessage_PreflightUserCache(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"access_token\":\"token\",\"expires_in\":7200}"))
+		case "/cgi-bin/agent/get":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"agentid\":123,\"allow_partys\":{\"partyid\":[2]}}"))
+		case "/cgi-bin/department/list":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"department\":[{\"id\":2,\"name\":\"Engineering\",\"parentid\":1}]}"))
+		case "/cgi-bin/user/get":
+			atomic.AddInt32(&calls, 1)
+			if r.URL.Query().Get("userid") == "party_user" {
+				_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"userid\":\"party_user\",\"department\":[2]}"))
+			} else {
+				_, _ = w.Write([]byte("{\"errcode\":60111,\"errmsg\":\"userid not found\"}"))
+			}
+		}
+	}))
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	for i := 0; i < 3; i++ {
+		report, err := tempWx.M().ToUser([]string{"party_user", "unknown_user"}).Preflight()
+		assertEqual(t, err, nil)
+		assertEqual(t, report.OutOfScope.Users, []string{"unknown_user"})
+	}
+	assertEqual(t, atomic.LoadInt32(&calls), int32(2))
+}
+
+func TestMessage_PreflightUserError(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	_, err := tempWx.M().ToUser([]string{"busy_user"}).Preflight()
+	var apiErr *wxcom.ApiError
+	assertEqual(t, errors.As(err, &apiErr), true)
+	assertEqual(t, apiErr.Errcode, 45009)
+}
+
+func TestMessage_PreflightTagParty(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"access_token\":\"token\",\"expires_in\":7200}"))
+		case "/cgi-bin/agent/get":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"agentid\":123,\"allow_tags\":{\"tagid\":[3]}}"))
+		case "/cgi-bin/tag/get":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"tagname\":\"oncall\",\"userlist\":[],\"partylist\":[7]}"))
+		case "/cgi-bin/department/list":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"department\":[{\"id\":7,\"name\":\"SRE\",\"parentid\":1},{\"id\":8,\"name\":\"Oncall\",\"parentid\":7},{\"id\":9,\"name\":\"Sales\",\"parentid\":1}]}"))
+		default:
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"msgid\":\"msgid\"}"))
+		}
+	}))
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	report, err := tempWx.M().ToParty([]string{"7", "8", "9"}).Preflight()
+	assertEqual(t, err, nil)
+	assertEqual(t, report.OutOfScope.Parties, []string{"9"})
+
+	resp, err := tempWx.M().ToParty([]string{"7", "8"}).StrictScope().Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Msgid, "msgid")
+}
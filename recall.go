@@ -0,0 +1,148 @@
+package wxcom
+
+import (
+	"errors"
+	"github.com/patrickmn/go-cache"
+	"sync"
+	"time"
+)
+
+// RespRecall struct holds response values of recall message.
+type RespRecall struct {
+	respCommon
+}
+
+// SendLog interface records the msgid of the sent messages by the correlation id,
+// so that all the messages sent for the same correlation id can be recalled.
+type SendLog interface {
+	// Record records the msgid under the correlation id.
+	Record(correlationId, msgid string) error
+	// Msgids returns the msgids recorded under the correlation id.
+	Msgids(correlationId string) ([]string, error)
+	// Remove removes the msgid recorded under the correlation id.
+	Remove(correlationId, msgid string) error
+}
+
+// memorySendLog struct is the in-memory SendLog.
+type memorySendLog struct {
+	mu    sync.Mutex
+	cache *cache.Cache
+}
+
+// NewMemorySendLog method creates a new in-memory SendLog.
+// The records expire after 24 hours, since the message can only be recalled within 24 hours.
+func NewMemorySendLog() SendLog {
+	return &memorySendLog{
+		cache: cache.New(24*time.Hour, time.Hour),
+	}
+}
+
+// Record method records the msgid under the correlation id.
+func (l *memorySendLog) Record(correlationId, msgid string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var msgids []string
+	if value, found := l.cache.Get(correlationId); found {
+		msgids = value.([]string)
+	}
+	l.cache.SetDefault(correlationId, append(msgids[:len(msgids):len(msgids)], msgid))
+
+	return nil
+}
+
+// Msgids method returns the msgids recorded under the correlation id.
+func (l *memorySendLog) Msgids(correlationId string) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if value, found := l.cache.Get(correlationId); found {
+		return value.([]string), nil
+	}
+
+	return nil, nil
+}
+
+// Remove method removes the msgid recorded under the correlation id.
+func (l *memorySendLog) Remove(correlationId, msgid string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	value, found := l.cache.Get(correlationId)
+	if !found {
+		return nil
+	}
+
+	var msgids []string
+	for _, id := range value.([]string) {
+		if id != msgid {
+			msgids = append(msgids, id)
+		}
+	}
+	if len(msgids) == 0 {
+		l.cache.Delete(correlationId)
+	} else {
+		l.cache.SetDefault(correlationId, msgids)
+	}
+
+	return nil
+}
+
+// SetSendLog method sets the send log used to record the messages sent with correlation id.
+func (w *Wxcom) SetSendLog(sendLog SendLog) *Wxcom {
+	w.sendLog = sendLog
+	return w
+}
+
+// OnSendLogError method sets the callback of the msgids failed to record in the send log.
+// The send is not failed by the send log, since the message has been sent.
+func (w *Wxcom) OnSendLogError(fn func(correlationId, msgid string, err error)) *Wxcom {
+	w.onSendLogError = fn
+	return w
+}
+
+// Recall method recalls the message sent within 24 hours by msgid.
+func (w *Wxcom) Recall(msgid string) (*RespRecall, error) {
+	response := &RespRecall{}
+
+	if msgid == "" {
+		return nil, errors.New("msgid cannot be empty")
+	}
+
+	err := w.sendWithRetry("/cgi-bin/message/recall", nil, map[string]interface{}{"msgid": msgid}, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// RecallByCorrelationId method recalls all the messages sent with the correlation id.
+// The recalled msgids are removed from the send log, the failed ones are kept for the next attempt.
+func (w *Wxcom) RecallByCorrelationId(correlationId string) (map[string]*RespRecall, error) {
+	if w.sendLog == nil {
+		return nil, errors.New("send log is not set")
+	}
+
+	msgids, err := w.sendLog.Msgids(correlationId)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make(map[string]*RespRecall, len(msgids))
+	for _, msgid := range msgids {
+		resp, err := w.Recall(msgid)
+		if err != nil {
+			return responses, err
+		}
+		responses[msgid] = resp
+
+		if resp.Errcode == 0 {
+			if err = w.sendLog.Remove(correlationId, msgid); err != nil {
+				return responses, err
+			}
+		}
+	}
+
+	return responses, nil
+}
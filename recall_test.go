@@ -0,0 +1,85 @@
+package wxcom_test
+
+import (
+	"errors"
+	"github.com/mingzaily/go-wxcom"
+	"testing"
+)
+
+func TestWxcom_Recall(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.Recall("msgid")
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+
+	_, err = tempWx.Recall("")
+	assertEqual(t, err.Error(), "msgid cannot be empty")
+}
+
+func TestWxcom_RecallByCorrelationId(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	sendLog := wxcom.NewMemorySendLog()
+	tempWx := wxcom.New("123", "321", 123).SetSendLog(sendLog)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	_, err := tempWx.M().ToUser([]string{"test"}).CorrelationId("incident").Text("测试TEXT").Send()
+	assertEqual(t, err, nil)
+
+	msgids, _ := sendLog.Msgids("incident")
+	assertEqual(t, msgids, []string{"msgid"})
+
+	resps, err := tempWx.RecallByCorrelationId("incident")
+	assertEqual(t, err, nil)
+	assertEqual(t, resps["msgid"].Errcode, 0)
+
+	msgids, _ = sendLog.Msgids("incident")
+	assertEqual(t, len(msgids), 0)
+}
+
+func TestMemorySendLog(t *testing.T) {
+	sendLog := wxcom.NewMemorySendLog()
+
+	_ = sendLog.Record("a", "1")
+	_ = sendLog.Record("a", "2")
+	_ = sendLog.Record("b", "3")
+
+	msgids, _ := sendLog.Msgids("a")
+	assertEqual(t, msgids, []string{"1", "2"})
+
+	_ = sendLog.Remove("a", "1")
+	msgids, _ = sendLog.Msgids("a")
+	assertEqual(t, msgids, []string{"2"})
+}
+
+type failingSendLog struct {
+	wxcom.SendLog
+}
+
+func (l failingSendLog) Record(correlationId, msgid string) error {
+	return errors.New("send log is down")
+}
+
+func TestWxcom_SendLogError(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	var failed string
+	tempWx := wxcom.New("123", "321", 123).
+		SetSendLog(failingSendLog{wxcom.NewMemorySendLog()}).
+		OnSendLogError(func(correlationId, msgid string, err error) {
+			failed = correlationId + ":" + msgid + ":" + err.Error()
+		})
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.M().ToUser([]string{"test"}).CorrelationId("incident").Text("测试TEXT").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Msgid, "msgid")
+	assertEqual(t, failed, "incident:msgid:send log is down")
+}
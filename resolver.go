@@ -0,0 +1,231 @@
+package wxcom
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resolver struct is used to resolve the emails, mobiles and department paths into the recipients of the message.
+//
+// The inputs of users are resolved by their form:
+//
+//	email, such as "someone@example.com":   resolved by `user/get_userid_by_email`.
+//	mobile, such as "13800000000":          resolved by `user/getuserid`, "+" and "-" are allowed.
+//	others:                                 used as userid.
+//
+// The department paths are the names of the departments separated by "/", such as "Engineering/SRE",
+// and they are matched from the last name up, so the path does not need to start from the root department.
+type Resolver struct {
+	wx         *Wxcom
+	emailType  int
+	expiration time.Duration
+}
+
+// UnresolvedError struct is returned when some of the inputs cannot be resolved.
+type UnresolvedError struct {
+	Inputs []string
+	Errors []error
+}
+
+// Error method implements the error interface.
+func (e *UnresolvedError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// NewResolver method creates a new Resolver instance.
+// The resolved userids are cached for 1 hour.
+func (w *Wxcom) NewResolver() *Resolver {
+	return &Resolver{
+		wx:         w,
+		emailType:  1,
+		expiration: time.Hour,
+	}
+}
+
+// SetEmailType method sets the type of the emails, 1 for the corp email, 2 for the personal email.
+func (r *Resolver) SetEmailType(emailType int) *Resolver {
+	r.emailType = emailType
+	return r
+}
+
+// SetExpiration method sets how long the resolved userids are cached.
+func (r *Resolver) SetExpiration(expiration time.Duration) *Resolver {
+	r.expiration = expiration
+	return r
+}
+
+// Resolve method resolves the users and department paths into recipients.
+// The resolved recipients are returned with an *UnresolvedError when some of the inputs cannot be resolved.
+func (r *Resolver) Resolve(users, departments []string) (Recipients, error) {
+	unresolved := &UnresolvedError{}
+
+	var recipients Recipients
+	for _, input := range users {
+		userid, err := r.ResolveUser(input)
+		if err != nil {
+			unresolved.add(input, err)
+			continue
+		}
+		recipients.Users = appendUnique(recipients.Users, userid)
+	}
+	for _, path := range departments {
+		id, err := r.ResolveDepartment(path)
+		if err != nil {
+			unresolved.add(path, err)
+			continue
+		}
+		recipients.Parties = appendUnique(recipients.Parties, strconv.Itoa(id))
+	}
+
+	if len(unresolved.Inputs) != 0 {
+		return recipients, unresolved
+	}
+	return recipients, nil
+}
+
+// ResolveUser method resolves the email, mobile or userid into userid.
+func (r *Resolver) ResolveUser(input string) (string, error) {
+	input = strings.TrimSpace(input)
+
+	var kind string
+	switch {
+	case isEmail(input):
+		kind = "email"
+	case isMobile(input):
+		kind = "mobile"
+		input = strings.NewReplacer("-", "", " ", "").Replace(input)
+	default:
+		return input, nil
+	}
+
+	cacheKey := fmt.Sprintf("userid_%s_%s", kind, input)
+	if value, found := r.wx.cache.Get(cacheKey); found {
+		return value.(string), nil
+	}
+
+	var resp *RespUserid
+	var err error
+	if kind == "email" {
+		resp, err = r.wx.GetUseridByEmail(input, r.emailType)
+	} else {
+		resp, err = r.wx.GetUseridByMobile(input)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err = resp.err(); err != nil {
+		return "", fmt.Errorf("%s %q: %w", kind, input, err)
+	}
+
+	r.wx.cache.Set(cacheKey, resp.Userid, r.expiration)
+	return resp.Userid, nil
+}
+
+// ResolveDepartment method resolves the department path into department id.
+func (r *Resolver) ResolveDepartment(path string) (int, error) {
+	names := strings.Split(strings.Trim(path, "/"), "/")
+	for i := range names {
+		names[i] = strings.TrimSpace(names[i])
+	}
+
+	departments, err := r.wx.cachedDepartments()
+	if err != nil {
+		return 0, err
+	}
+
+	byId := make(map[int]Department, len(departments))
+	for _, department := range departments {
+		byId[department.Id] = department
+	}
+
+	var matches []int
+	for _, department := range departments {
+		if matchDepartmentPath(byId, department, names) {
+			matches = append(matches, department.Id)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return 0, fmt.Errorf("department %q is not found", path)
+	case 1:
+		return matches[0], nil
+	default:
+		return 0, fmt.Errorf("department %q is ambiguous, matches %d departments", path, len(matches))
+	}
+}
+
+// ToRecipients method sets the resolved recipients in the current message.
+func (m *Message) ToRecipients(recipients Recipients) *Message {
+	m.toUser = recipients.Users
+	m.toParty = recipients.Parties
+	m.toTag = recipients.Tags
+	return m
+}
+
+// add method adds the unresolved input and its error.
+func (e *UnresolvedError) add(input string, err error) {
+	e.Inputs = append(e.Inputs, input)
+	e.Errors = append(e.Errors, err)
+}
+
+// matchDepartmentPath method checks the names match the department and its ancestors from the last name up.
+func matchDepartmentPath(byId map[int]Department, department Department, names []string) bool {
+	for i := len(names) - 1; i >= 0; i-- {
+		if department.Name != names[i] {
+			return false
+		}
+		if i == 0 {
+			break
+		}
+		parent, found := byId[department.Parentid]
+		if !found || parent.Id == department.Id {
+			return false
+		}
+		department = parent
+	}
+	return true
+}
+
+// isEmail method reports whether the input looks like an email.
+func isEmail(input string) bool {
+	at := strings.LastIndex(input, "@")
+	return at > 0 && at < len(input)-1 && strings.Contains(input[at:], ".")
+}
+
+// isMobile method reports whether the input looks like a mobile, such as "13800000000" or "+86-13800000000".
+// The input without the country code must be the mainland mobile of 11 digits starting with 1,
+// so that the numeric userids, such as the employee numbers, are not taken as mobiles.
+func isMobile(input string) bool {
+	digits := 0
+	for i, c := range input {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case c == '+' && i == 0, c == '-', c == ' ':
+		default:
+			return false
+		}
+	}
+
+	if strings.HasPrefix(input, "+") {
+		return digits >= 6
+	}
+	return digits == 11 && strings.TrimLeft(input, "- ")[0] == '1'
+}
+
+// appendUnique method appends the value if it is not in the list.
+func appendUnique(list []string, value string) []string {
+	for _, item := range list {
+		if item == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
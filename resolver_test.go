@@ -0,0 +1,80 @@
+package wxcom_test
+
+import (
+	"errors"
+	"github.com/mingzaily/go-wxcom"
+	"testing"
+)
+
+func TestResolver_ResolveUser(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+	resolver := tempWx.NewResolver()
+
+	userid, err := resolver.ResolveUser("someone@example.com")
+	assertEqual(t, err, nil)
+	assertEqual(t, userid, "email_user")
+
+	userid, err = resolver.ResolveUser("138-0000-0000")
+	assertEqual(t, err, nil)
+	assertEqual(t, userid, "mobile_user")
+
+	userid, err = resolver.ResolveUser("zhangsan")
+	assertEqual(t, err, nil)
+	assertEqual(t, userid, "zhangsan")
+
+	// the employee number is not a mobile
+	userid, err = resolver.ResolveUser("100234")
+	assertEqual(t, err, nil)
+	assertEqual(t, userid, "100234")
+
+	_, err = resolver.ResolveUser("nobody@example.com")
+	var apiErr *wxcom.ApiError
+	assertEqual(t, errors.As(err, &apiErr), true)
+	assertEqual(t, apiErr.Errcode, 46004)
+}
+
+func TestResolver_ResolveDepartment(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+	resolver := tempWx.NewResolver()
+
+	id, err := resolver.ResolveDepartment("Engineering/SRE")
+	assertEqual(t, err, nil)
+	assertEqual(t, id, 4)
+
+	id, err = resolver.ResolveDepartment("/Company/Sales")
+	assertEqual(t, err, nil)
+	assertEqual(t, id, 5)
+
+	_, err = resolver.ResolveDepartment("Sales/SRE")
+	assertNotEqual(t, err, nil)
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	recipients, err := tempWx.NewResolver().Resolve(
+		[]string{"someone@example.com", "13800000000", "email_user", "nobody@example.com"},
+		[]string{"Engineering/SRE", "Marketing"},
+	)
+	assertEqual(t, recipients.Users, []string{"email_user", "mobile_user"})
+	assertEqual(t, recipients.Parties, []string{"4"})
+
+	var unresolved *wxcom.UnresolvedError
+	assertEqual(t, errors.As(err, &unresolved), true)
+	assertEqual(t, unresolved.Inputs, []string{"nobody@example.com", "Marketing"})
+
+	msg := tempWx.M().ToRecipients(recipients)
+	assertJson(t, msg.Text("content"), "{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"text\",\"safe\":0,\"text\":{\"content\":\"content\"},\"toparty\":\"4\",\"touser\":\"email_user|mobile_user\"}")
+}
@@ -0,0 +1,116 @@
+package wxcom
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Recipients struct holds the users, parties and tags of a message.
+type Recipients struct {
+	Users   []string `json:"users,omitempty"`
+	Parties []string `json:"parties,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+// Empty method reports whether there is no recipient.
+func (r Recipients) Empty() bool {
+	return len(r.Users) == 0 && len(r.Parties) == 0 && len(r.Tags) == 0
+}
+
+// SendResult struct holds the structured result of send message.
+type SendResult struct {
+	Msgids          []string
+	InvalidUsers    []string
+	InvalidParties  []string
+	InvalidTags     []string
+	UnlicensedUsers []string
+	// Failed holds the recipients of the batches failed with errcode or error, which are not delivered at all.
+	Failed    Recipients
+	requested Recipients
+	failures  int
+}
+
+// PartialFailureError struct is returned when some of the recipients are not delivered
+// and the message is sent with StrictDelivery.
+type PartialFailureError struct {
+	Result *SendResult
+}
+
+// Error method implements the error interface.
+func (e *PartialFailureError) Error() string {
+	return fmt.Sprintf("message partially failed: invalid users [%s], invalid parties [%s], invalid tags [%s], unlicensed users [%s], "+
+		"failed users [%s], failed parties [%s], failed tags [%s]",
+		strings.Join(e.Result.InvalidUsers, "|"),
+		strings.Join(e.Result.InvalidParties, "|"),
+		strings.Join(e.Result.InvalidTags, "|"),
+		strings.Join(e.Result.UnlicensedUsers, "|"),
+		strings.Join(e.Result.Failed.Users, "|"),
+		strings.Join(e.Result.Failed.Parties, "|"),
+		strings.Join(e.Result.Failed.Tags, "|"))
+}
+
+// Result method returns the structured result of the response.
+func (r *RespMessage) Result() *SendResult {
+	return &SendResult{
+		Msgids:          r.Msgids,
+		InvalidUsers:    splitRecipients(r.Invaliduser),
+		InvalidParties:  splitRecipients(r.Invalidparty),
+		InvalidTags:     splitRecipients(r.Invalidtag),
+		UnlicensedUsers: splitRecipients(r.Unlicenseduser),
+		Failed: Recipients{
+			Users:   excludeRecipients(r.failed.Users),
+			Parties: excludeRecipients(r.failed.Parties),
+			Tags:    excludeRecipients(r.failed.Tags),
+		},
+		requested: r.requested,
+		failures:  r.failures,
+	}
+}
+
+// PartialFailure method reports whether some of the recipients are not delivered,
+// including the batches failed with errcode or error.
+func (r *SendResult) PartialFailure() bool {
+	return len(r.InvalidUsers) != 0 || len(r.InvalidParties) != 0 ||
+		len(r.InvalidTags) != 0 || len(r.UnlicensedUsers) != 0 || r.failures != 0
+}
+
+// Delivered method returns the requested recipients excluding the invalid, unlicensed and failed ones.
+func (r *SendResult) Delivered() Recipients {
+	return Recipients{
+		Users:   excludeRecipients(r.requested.Users, r.InvalidUsers, r.UnlicensedUsers, r.Failed.Users),
+		Parties: excludeRecipients(r.requested.Parties, r.InvalidParties, r.Failed.Parties),
+		Tags:    excludeRecipients(r.requested.Tags, r.InvalidTags, r.Failed.Tags),
+	}
+}
+
+// splitRecipients method splits the `|` joined recipients, duplicates are removed.
+func splitRecipients(value string) []string {
+	var list []string
+	seen := make(map[string]struct{})
+	for _, item := range strings.Split(value, "|") {
+		if _, found := seen[item]; item != "" && !found {
+			list = append(list, item)
+			seen[item] = struct{}{}
+		}
+	}
+	return list
+}
+
+// excludeRecipients method returns the recipients not in the excluded lists, duplicates are removed.
+func excludeRecipients(list []string, excludes ...[]string) []string {
+	excluded := make(map[string]struct{})
+	for _, exclude := range excludes {
+		for _, item := range exclude {
+			excluded[item] = struct{}{}
+		}
+	}
+
+	var result []string
+	for _, item := range list {
+		if _, found := excluded[item]; !found {
+			result = append(result, item)
+			excluded[item] = struct{}{}
+		}
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package wxcom_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mingzaily/go-wxcom"
+	"testing"
+)
+
+func TestRespMessage_Result(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.NewMessage().
+		ToUser([]string{"test", "invalid_user", "unlicensed_user"}).
+		ToParty([]string{"1"}).
+		Text("测试TEXT").
+		Send()
+	assertEqual(t, err, nil)
+
+	result := resp.Result()
+	assertEqual(t, result.PartialFailure(), true)
+	assertEqual(t, result.Msgids, []string{"msgid"})
+	assertEqual(t, result.InvalidUsers, []string{"invalid_user"})
+	assertEqual(t, result.UnlicensedUsers, []string{"unlicensed_user"})
+	assertEqual(t, result.Delivered(), wxcom.Recipients{Users: []string{"test"}, Parties: []string{"1"}})
+}
+
+func TestMessage_StrictDelivery(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.NewMessage().
+		ToUser([]string{"test", "invalid_user"}).
+		StrictDelivery().
+		Text("测试TEXT").
+		Send()
+
+	var partialErr *wxcom.PartialFailureError
+	assertEqual(t, errors.As(err, &partialErr), true)
+	assertEqual(t, partialErr.Result.InvalidUsers, []string{"invalid_user"})
+	assertEqual(t, resp.Msgid, "msgid")
+
+	_, err = tempWx.NewMessage().ToUser([]string{"test"}).StrictDelivery().Text("测试TEXT").Send()
+	assertEqual(t, err, nil)
+}
+
+func TestRespMessage_ResultFailedBatch(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	var users []string
+	for i := 0; i < 1000; i++ {
+		users = append(users, fmt.Sprintf("user%d", i))
+	}
+	users = append(users, "quota_user")
+
+	resp, err := tempWx.NewMessage().ToUser(users).Text("测试TEXT").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 45009)
+
+	result := resp.Result()
+	assertEqual(t, result.PartialFailure(), true)
+	assertEqual(t, result.Msgids, []string{"msgid"})
+	assertEqual(t, result.Failed, wxcom.Recipients{Users: []string{"quota_user"}})
+	assertEqual(t, result.Delivered(), wxcom.Recipients{Users: users[:1000]})
+
+	_, err = tempWx.NewMessage().ToUser(users).StrictDelivery().Text("测试TEXT").Send()
+	var partialErr *wxcom.PartialFailureError
+	assertEqual(t, errors.As(err, &partialErr), true)
+	assertEqual(t, partialErr.Result.Failed.Users, []string{"quota_user"})
+
+	resp, err = tempWx.NewMessage().ToUser([]string{"quota_user"}).Text("测试TEXT").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Result().PartialFailure(), true)
+	assertEqual(t, resp.Result().Delivered(), wxcom.Recipients{})
+}
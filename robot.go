@@ -0,0 +1,132 @@
+package wxcom
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"github.com/patrickmn/go-cache"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// webhookSendPath is the path of sending message by robot.
+	webhookSendPath = "/cgi-bin/webhook/send"
+	// webhookUploadPath is the path of uploading media by robot.
+	webhookUploadPath = "/cgi-bin/webhook/upload_media"
+)
+
+// Robot struct is used to create group robot client, which sends messages by the webhook key without access token.
+//
+// The robot supports text, markdown, markdown_v2, image, news, file, voice and template card(text_notice and news_notice).
+// The image is created by Message.ImageData, and the file and voice are uploaded by UploadMedia first.
+type Robot struct {
+	key   string
+	wx    *Wxcom
+	Resty *resty.Client
+}
+
+// robotSender interface is implemented by Robot and RobotPool to send the robot message.
+type robotSender interface {
+	send(path string, payload interface{}, result *RespMessage) error
+	// keys returns the webhook keys of the sender.
+	keys() []string
+}
+
+// RespRobotUpload struct holds response values of robot upload media.
+type RespRobotUpload struct {
+	respCommon
+	Type      string `json:"type"`
+	MediaId   string `json:"media_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+// NewRobot method creates a new Robot client by the webhook key, or the webhook url containing the key.
+func NewRobot(key string) *Robot {
+	if strings.Contains(key, "key=") {
+		if u, err := url.Parse(key); err == nil && u.Query().Get("key") != "" {
+			key = u.Query().Get("key")
+		}
+	}
+
+	client := resty.New().SetBaseURL("https://qyapi.weixin.qq.com/")
+	return &Robot{
+		key:   key,
+		wx:    newTokenlessWxcom(client),
+		Resty: client,
+	}
+}
+
+// newTokenlessWxcom method creates the client holding no credentials for the robot messages.
+func newTokenlessWxcom(client *resty.Client) *Wxcom {
+	return &Wxcom{
+		cache: cache.New(5*time.Minute, 10*time.Minute),
+		Resty: client,
+	}
+}
+
+// GetKey method get webhook key from robot.
+func (r *Robot) GetKey() string {
+	return r.key
+}
+
+// M method creates a new Message instance sent by the robot.
+// The robot message has no recipients, use text SetMentionedList to mention the members.
+func (r *Robot) M() *Message {
+	return &Message{
+		wx:     r.wx,
+		target: robotTarget(r),
+	}
+}
+
+// NewMessage is an alias for method `M()`. Creates a new Message instance sent by the robot.
+func (r *Robot) NewMessage() *Message {
+	return r.M()
+}
+
+// UploadMedia method uploads the file or voice for the robot, the media id is valid for 3 days.
+// Param mediaType is "file" or "voice", the voice must be amr.
+func (r *Robot) UploadMedia(mediaType, filename string, reader io.Reader) (*RespRobotUpload, error) {
+	response := &RespRobotUpload{}
+
+	if mediaType != "file" && mediaType != "voice" {
+		return nil, fmt.Errorf("unsupported media type %q", mediaType)
+	}
+
+	_, err := r.Resty.R().
+		SetQueryParam("key", r.key).
+		SetQueryParam("type", mediaType).
+		SetFileReader("media", filename, reader).
+		SetResult(response).
+		SetError(response).
+		Post(webhookUploadPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// keys method returns the webhook key of the robot.
+func (r *Robot) keys() []string {
+	return []string{r.key}
+}
+
+// send method posts the payload by the webhook key.
+func (r *Robot) send(path string, payload interface{}, result *RespMessage) error {
+	if r.key == "" {
+		return errors.New("robot key cannot be empty")
+	}
+
+	_, err := r.Resty.R().
+		SetHeader("Content-Type", "application/json; charset=UTF-8").
+		SetQueryParam("key", r.key).
+		SetBody(payload).
+		SetResult(result).
+		SetError(result).
+		Post(path)
+
+	return err
+}
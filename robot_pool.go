@@ -0,0 +1,217 @@
+package wxcom
+
+import (
+	"errors"
+	"github.com/go-resty/resty/v2"
+	"sync"
+	"time"
+)
+
+const (
+	// robotRateLimit is the max count of messages a robot sends per minute.
+	robotRateLimit = 20
+	// errcodeFrequencyLimit is returned when the api frequency is out of limit.
+	errcodeFrequencyLimit = 45009
+	// errcodeInvalidWebhook is returned when the webhook key is invalid.
+	errcodeInvalidWebhook = 93000
+)
+
+// ErrRobotPoolBusy is returned when all the robots are at the rate limit longer than the max wait.
+var ErrRobotPoolBusy = errors.New("all the robots are at the rate limit")
+
+// ErrRobotPoolUnavailable is returned when no robot can be used, since all the keys are invalid or have failed.
+var ErrRobotPoolUnavailable = errors.New("no robot is available")
+
+// RobotPool struct is used to send messages by several robots of the same group to get more throughput.
+//
+// Each robot is limited to 20 messages per minute. The pool tracks the messages of each key in the sliding window,
+// rotates the keys, and waits for the first free slot when all the keys are at the limit.
+// The key returning the frequency error(45009) cools down, and the key returning the invalid key error(93000)
+// is disabled, and the message fails over to the next key.
+//
+// The media uploaded by a robot can only be sent by the same robot, so send file and voice by the Robot directly.
+type RobotPool struct {
+	wx       *Wxcom
+	limit    int
+	window   time.Duration
+	maxWait  time.Duration
+	cooldown time.Duration
+
+	mu     sync.Mutex
+	robots []*pooledRobot
+	next   int
+}
+
+// pooledRobot struct holds the usage of a robot in the pool.
+type pooledRobot struct {
+	robot     *Robot
+	sent      []time.Time
+	coolUntil time.Time
+	disabled  bool
+}
+
+// NewRobotPool method creates a new RobotPool instance of the robots.
+// By default, each robot sends at most 20 messages per minute, and the send waits at most 1 minute for a free slot.
+func NewRobotPool(robots ...*Robot) *RobotPool {
+	p := &RobotPool{
+		wx:       newTokenlessWxcom(resty.New()),
+		limit:    robotRateLimit,
+		window:   time.Minute,
+		maxWait:  time.Minute,
+		cooldown: time.Minute,
+	}
+	for _, robot := range robots {
+		p.robots = append(p.robots, &pooledRobot{robot: robot})
+	}
+	return p
+}
+
+// SetRateLimit method sets the max count of messages each robot sends in the window.
+func (p *RobotPool) SetRateLimit(limit int, window time.Duration) *RobotPool {
+	p.limit = limit
+	p.window = window
+	return p
+}
+
+// SetMaxWait method sets how long the send waits for a free slot, ErrRobotPoolBusy is returned if exceeded.
+// The send does not wait if it is 0.
+func (p *RobotPool) SetMaxWait(maxWait time.Duration) *RobotPool {
+	p.maxWait = maxWait
+	return p
+}
+
+// SetCooldown method sets how long the robot returning the frequency error is not used.
+func (p *RobotPool) SetCooldown(cooldown time.Duration) *RobotPool {
+	p.cooldown = cooldown
+	return p
+}
+
+// Usage method returns the count of messages sent by each key in the current window.
+func (p *RobotPool) Usage() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	usage := make(map[string]int, len(p.robots))
+	for _, r := range p.robots {
+		r.prune(now, p.window)
+		usage[r.robot.key] = len(r.sent)
+	}
+	return usage
+}
+
+// M method creates a new Message instance sent by the robots of the pool.
+func (p *RobotPool) M() *Message {
+	return &Message{
+		wx:     p.wx,
+		target: robotTarget(p),
+	}
+}
+
+// NewMessage is an alias for method `M()`. Creates a new Message instance sent by the robots of the pool.
+func (p *RobotPool) NewMessage() *Message {
+	return p.M()
+}
+
+// keys method returns the webhook keys of the robots.
+func (p *RobotPool) keys() []string {
+	keys := make([]string, len(p.robots))
+	for i, r := range p.robots {
+		keys[i] = r.robot.key
+	}
+	return keys
+}
+
+// send method sends the payload by the next robot with free slot, and fails over to the other robots.
+// The response of the last robot is returned if all the robots have failed.
+func (p *RobotPool) send(path string, payload interface{}, result *RespMessage) error {
+	tried := make(map[*pooledRobot]struct{})
+
+	var lastErr error
+	for {
+		r, err := p.acquire(tried)
+		if err == ErrRobotPoolUnavailable && len(tried) != 0 {
+			return lastErr
+		}
+		if err != nil {
+			return err
+		}
+		tried[r] = struct{}{}
+
+		*result = RespMessage{}
+		lastErr = r.robot.send(path, payload, result)
+		if lastErr != nil {
+			continue
+		}
+
+		switch result.Errcode {
+		case errcodeFrequencyLimit:
+			p.mu.Lock()
+			r.coolUntil = time.Now().Add(p.cooldown)
+			p.mu.Unlock()
+		case errcodeInvalidWebhook:
+			p.mu.Lock()
+			r.disabled = true
+			p.mu.Unlock()
+		default:
+			return nil
+		}
+	}
+}
+
+// acquire method takes a slot of the next robot not tried, and waits if all of them are at the limit.
+func (p *RobotPool) acquire(tried map[*pooledRobot]struct{}) (*pooledRobot, error) {
+	deadline := time.Now().Add(p.maxWait)
+
+	for {
+		p.mu.Lock()
+		now := time.Now()
+
+		available := false
+		wait := time.Duration(-1)
+		for i := range p.robots {
+			index := (p.next + i) % len(p.robots)
+			r := p.robots[index]
+			if _, found := tried[r]; found || r.disabled {
+				continue
+			}
+			available = true
+
+			var free time.Time
+			r.prune(now, p.window)
+			switch {
+			case now.Before(r.coolUntil):
+				free = r.coolUntil
+			case p.limit > 0 && len(r.sent) >= p.limit:
+				free = r.sent[len(r.sent)-p.limit].Add(p.window)
+			default:
+				r.sent = append(r.sent, now)
+				p.next = index + 1
+				p.mu.Unlock()
+				return r, nil
+			}
+
+			if w := free.Sub(now); wait < 0 || w < wait {
+				wait = w
+			}
+		}
+		p.mu.Unlock()
+
+		if !available {
+			return nil, ErrRobotPoolUnavailable
+		}
+		if now.Add(wait).After(deadline) {
+			return nil, ErrRobotPoolBusy
+		}
+		time.Sleep(wait)
+	}
+}
+
+// prune method removes the sends out of the window.
+func (r *pooledRobot) prune(now time.Time, window time.Duration) {
+	i := 0
+	for i < len(r.sent) && !r.sent[i].Add(window).After(now) {
+		i++
+	}
+	r.sent = r.sent[i:]
+}
@@ -0,0 +1,73 @@
+package wxcom_test
+
+import (
+	"github.com/mingzaily/go-wxcom"
+	"testing"
+	"time"
+)
+
+func createTestRobotPool(ts string, keys ...string) *wxcom.RobotPool {
+	robots := make([]*wxcom.Robot, len(keys))
+	for i, key := range keys {
+		robots[i] = wxcom.NewRobot(key)
+		robots[i].Resty.SetBaseURL(ts)
+	}
+	return wxcom.NewRobotPool(robots...)
+}
+
+func TestRobotPool_Rotate(t *testing.T) {
+	ts := createRobotServer(t)
+	defer ts.Close()
+
+	pool := createTestRobotPool(ts.URL, "robot_key1", "robot_key2").SetRateLimit(2, time.Minute).SetMaxWait(0)
+
+	for i := 0; i < 4; i++ {
+		resp, err := pool.M().Text("content").Send()
+		assertEqual(t, err, nil)
+		assertEqual(t, resp.Errcode, 0)
+	}
+	assertEqual(t, pool.Usage(), map[string]int{"robot_key1": 2, "robot_key2": 2})
+
+	_, err := pool.M().Text("content").Send()
+	assertEqual(t, err, wxcom.ErrRobotPoolBusy)
+}
+
+func TestRobotPool_Wait(t *testing.T) {
+	ts := createRobotServer(t)
+	defer ts.Close()
+
+	pool := createTestRobotPool(ts.URL, "robot_key").SetRateLimit(1, 100*time.Millisecond).SetMaxWait(time.Second)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		resp, err := pool.M().Text("content").Send()
+		assertEqual(t, err, nil)
+		assertEqual(t, resp.Errcode, 0)
+	}
+	assertEqual(t, time.Since(start) >= 200*time.Millisecond, true)
+}
+
+func TestRobotPool_Failover(t *testing.T) {
+	ts := createRobotServer(t)
+	defer ts.Close()
+
+	pool := createTestRobotPool(ts.URL, "busy_key", "invalid_key", "robot_key").SetMaxWait(0)
+
+	resp, err := pool.M().Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+
+	// the busy key cools down and the invalid key is disabled
+	resp, err = pool.M().Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+	assertEqual(t, pool.Usage(), map[string]int{"busy_key": 1, "invalid_key": 1, "robot_key": 2})
+
+	pool = createTestRobotPool(ts.URL, "invalid_key")
+	resp, err = pool.M().Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 93000)
+
+	_, err = pool.M().Text("content").Send()
+	assertEqual(t, err, wxcom.ErrRobotPoolUnavailable)
+}
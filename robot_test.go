@@ -0,0 +1,117 @@
+package wxcom_test
+
+import (
+	"bytes"
+	"github.com/mingzaily/go-wxcom"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func createRobotServer(t *testing.T) *httptest.Server {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		t.Logf("Path: %v", r.URL.Path)
+
+		w.Header().Set("Content-Type", "application/json")
+		key := r.URL.Query().Get("key")
+		if key == "busy_key" {
+			_, _ = w.Write([]byte("{\"errcode\":45009,\"errmsg\":\"api freq out of limit\"}"))
+			return
+		}
+		if r.URL.Query().Get("access_token") != "" || !strings.HasPrefix(key, "robot_key") {
+			_, _ = w.Write([]byte("{\"errcode\":93000,\"errmsg\":\"invalid webhook url\"}"))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/cgi-bin/webhook/send":
+			body, _ := ioutil.ReadAll(r.Body)
+			if strings.Contains(string(body), "agentid") {
+				_, _ = w.Write([]byte("{\"errcode\":40003,\"errmsg\":\"invalid parameter\"}"))
+				return
+			}
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\"}"))
+		case "/cgi-bin/webhook/upload_media":
+			_, header, err := r.FormFile("media")
+			if err != nil {
+				_, _ = w.Write([]byte("{\"errcode\":40004,\"errmsg\":\"invalid media\"}"))
+				return
+			}
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"type\":\"" + r.URL.Query().Get("type") + "\",\"media_id\":\"" + header.Filename + "\",\"created_at\":\"1380000000\"}"))
+		}
+	}
+
+	return httptest.NewServer(http.HandlerFunc(fn))
+}
+
+func TestNewRobot(t *testing.T) {
+	assertEqual(t, wxcom.NewRobot("robot_key").GetKey(), "robot_key")
+	assertEqual(t, wxcom.NewRobot("https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=robot_key").GetKey(), "robot_key")
+}
+
+func TestRobot_Message(t *testing.T) {
+	robot := wxcom.NewRobot("robot_key")
+	msg := robot.M()
+
+	assertJson(t, msg.Text("content").SetMentionedList([]string{"user", "@all"}).SetMentionedMobileList([]string{"13800000000"}),
+		"{\"msgtype\":\"text\",\"text\":{\"content\":\"content\",\"mentioned_list\":[\"user\",\"@all\"],\"mentioned_mobile_list\":[\"13800000000\"]}}")
+	assertJson(t, msg.Markdown("**content**"), "{\"markdown\":{\"content\":\"**content**\"},\"msgtype\":\"markdown\"}")
+	assertJson(t, msg.MarkdownV2("| a | b |"), "{\"markdown_v2\":{\"content\":\"| a | b |\"},\"msgtype\":\"markdown_v2\"}")
+	assertJson(t, msg.ImageData([]byte("image")),
+		"{\"image\":{\"base64\":\"aW1hZ2U=\",\"md5\":\"78805a221a988e79ef3f42d7c5bfd418\"},\"msgtype\":\"image\"}")
+	assertJson(t, msg.File("media"), "{\"file\":{\"media_id\":\"media\"},\"msgtype\":\"file\"}")
+	assertJson(t, msg.Voice("media"), "{\"msgtype\":\"voice\",\"voice\":{\"media_id\":\"media\"}}")
+	assertJson(t, msg.News([]wxcom.NewsArticle{{Title: "title", Url: "https://test.com"}}),
+		"{\"msgtype\":\"news\",\"news\":{\"articles\":[{\"title\":\"title\",\"url\":\"https://test.com\"}]}}")
+
+	s, err := wx.M().FromJson("{\"touser\":\"user\",\"image\":{\"base64\":\"aW1hZ2U=\",\"md5\":\"78805a221a988e79ef3f42d7c5bfd418\"},\"msgtype\":\"image\"}")
+	assertEqual(t, err, nil)
+	assertEqual(t, s.Validate().Error(), "image.media_id cannot be empty; image data is only supported by robot")
+}
+
+func TestRobot_Validate(t *testing.T) {
+	msg := wxcom.NewRobot("robot_key").M()
+
+	assertEqual(t, msg.Clone().ToUser([]string{"user"}).Text("content").Validate().Error(), "toUser, toParty, toTag must be empty when sending by robot")
+	assertEqual(t, msg.Textcard("title", "description", "https://test.com").Validate().Error(), "textcard is not supported by robot")
+	assertEqual(t, msg.Video("media").Validate().Error(), "video is not supported by robot")
+	assertEqual(t, msg.Image("media").Validate().Error(), "image data cannot be empty")
+	assertEqual(t, msg.TemplateCard(&wxcom.TemplateCard{CardType: "button_interaction"}).Validate().Error(), "card type \"button_interaction\" is not supported by robot")
+	assertEqual(t, msg.Markdown(strings.Repeat("a", 4096)).Validate(), nil)
+
+	assertEqual(t, wx.M().ToUser([]string{"user"}).MarkdownV2("content").Validate().Error(), "markdown_v2 is only supported by robot")
+	assertEqual(t, wx.M().ToUser([]string{"user"}).Text("content").SetMentionedList([]string{"user"}).Validate().Error(),
+		"mentioned_list and mentioned_mobile_list are only supported by robot")
+}
+
+func TestRobot_Send(t *testing.T) {
+	ts := createRobotServer(t)
+	defer ts.Close()
+
+	robot := wxcom.NewRobot("robot_key")
+	robot.Resty.SetBaseURL(ts.URL)
+
+	resp, err := robot.M().Text("content").SetMentionedList([]string{"@all"}).Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+
+	upload, err := robot.UploadMedia("file", "report.txt", bytes.NewReader([]byte("report")))
+	assertEqual(t, err, nil)
+	assertEqual(t, upload.MediaId, "report.txt")
+	assertEqual(t, upload.Type, "file")
+
+	resp, err = robot.M().File(upload.MediaId).Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+
+	_, err = robot.UploadMedia("image", "image.png", bytes.NewReader([]byte("image")))
+	assertEqual(t, err.Error(), "unsupported media type \"image\"")
+
+	invalid := wxcom.NewRobot("invalid_key")
+	invalid.Resty.SetBaseURL(ts.URL)
+	resp, err = invalid.M().Text("content").Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 93000)
+}
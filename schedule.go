@@ -0,0 +1,502 @@
+package wxcom
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Schedule struct holds a message scheduled to be sent.
+type Schedule struct {
+	Id            string          `json:"id"`
+	Payload       *MessagePayload `json:"payload"`
+	CorrelationId string          `json:"correlation_id,omitempty"`
+	AutoSplit     bool            `json:"auto_split,omitempty"`
+	// IdempotencyKey is the key set by Message.IdempotencyKey, the fire time is appended for the recurring schedule.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	// At is the next time to send the message.
+	At time.Time `json:"at"`
+	// Cron is the cron expression of the recurring schedule, empty for the one-time schedule.
+	Cron string `json:"cron,omitempty"`
+	// Attempts is the count of the failed sends of the one-time schedule.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// ScheduleStore interface persists the pending schedules.
+type ScheduleStore interface {
+	// Put adds or updates the schedule.
+	Put(schedule *Schedule) error
+	// Remove removes the schedule.
+	Remove(id string) error
+	// All returns all the schedules.
+	All() ([]*Schedule, error)
+}
+
+// Scheduler struct is used to send messages at the given time, after a delay or by cron expression.
+//
+// The schedules are persisted in the store, so that they survive the process restarts.
+// The one-time schedules missed when the process is down are sent once started.
+// The one-time schedule failed with the retryable error is kept and sent again after the backoff,
+// while the recurring schedule waits for the next time.
+type Scheduler struct {
+	message     *Message
+	store       ScheduleStore
+	outbox      *Outbox
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	onError     func(schedule *Schedule, err error)
+	onFired     func(schedule *Schedule, err error)
+
+	mu      sync.Mutex
+	timers  map[string]*time.Timer
+	firing  map[string]bool
+	started bool
+	stopped bool
+}
+
+// NewScheduler method creates a new Scheduler instance with the store.
+func (w *Wxcom) NewScheduler(store ScheduleStore) *Scheduler {
+	return &Scheduler{
+		message:     w.M(),
+		store:       store,
+		maxAttempts: 5,
+		minBackoff:  time.Second,
+		maxBackoff:  time.Minute,
+		timers:      make(map[string]*time.Timer),
+		firing:      make(map[string]bool),
+	}
+}
+
+// SetOutbox method makes the due messages enqueued into the outbox instead of sent directly.
+func (s *Scheduler) SetOutbox(outbox *Outbox) *Scheduler {
+	s.outbox = outbox
+	return s
+}
+
+// SetMaxAttempts method sets the max attempts of sending the one-time schedule before it is removed.
+func (s *Scheduler) SetMaxAttempts(maxAttempts int) *Scheduler {
+	s.maxAttempts = maxAttempts
+	return s
+}
+
+// SetBackoff method sets the retry backoff of the one-time schedule, it doubles from min to max after every failed attempt.
+func (s *Scheduler) SetBackoff(min, max time.Duration) *Scheduler {
+	s.minBackoff = min
+	s.maxBackoff = max
+	return s
+}
+
+// OnError method sets the callback of the failed sends.
+func (s *Scheduler) OnError(fn func(schedule *Schedule, err error)) *Scheduler {
+	s.onError = fn
+	return s
+}
+
+// OnFired method sets the callback after the schedule is fired and the store is updated,
+// the err is the error of the send, nil if sent.
+func (s *Scheduler) OnFired(fn func(schedule *Schedule, err error)) *Scheduler {
+	s.onFired = fn
+	return s
+}
+
+// Start method loads the schedules from the store and starts the timers.
+func (s *Scheduler) Start() error {
+	schedules, err := s.store.All()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return errors.New("scheduler is already started")
+	}
+	s.started = true
+
+	for _, schedule := range schedules {
+		s.arm(schedule)
+	}
+
+	return nil
+}
+
+// Stop method stops all the timers, the schedules are kept in the store.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+	for id, timer := range s.timers {
+		timer.Stop()
+		delete(s.timers, id)
+	}
+}
+
+// At method schedules the message to be sent at the given time.
+func (s *Scheduler) At(at time.Time, m Sendable) (string, error) {
+	return s.add(m, at, "")
+}
+
+// After method schedules the message to be sent after the delay.
+func (s *Scheduler) After(delay time.Duration, m Sendable) (string, error) {
+	return s.add(m, time.Now().Add(delay), "")
+}
+
+// Cron method schedules the message to be sent repeatedly by the cron expression, in the local time zone.
+// The expression has 5 fields: minute, hour, day of month, month and day of week.
+func (s *Scheduler) Cron(expr string, m Sendable) (string, error) {
+	cron, err := parseCron(expr)
+	if err != nil {
+		return "", err
+	}
+
+	at := cron.next(time.Now())
+	if at.IsZero() {
+		return "", errors.New("cron expression never matches")
+	}
+
+	return s.add(m, at, expr)
+}
+
+// Cancel method cancels the schedule by id.
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if timer, found := s.timers[id]; found {
+		timer.Stop()
+		delete(s.timers, id)
+	}
+	if _, found := s.firing[id]; found {
+		// not rescheduled after the firing send
+		s.firing[id] = true
+	}
+
+	return s.store.Remove(id)
+}
+
+// add method validates and persists the schedule.
+func (s *Scheduler) add(m Sendable, at time.Time, cron string) (string, error) {
+	msg, err := buildMessage(s.message, m)
+	if err != nil {
+		return "", err
+	}
+
+	err = msg.validate()
+	if err != nil {
+		return "", err
+	}
+
+	err = msg.persistable()
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := msg.payload()
+	if err != nil {
+		return "", err
+	}
+
+	id, err := newRandomId()
+	if err != nil {
+		return "", err
+	}
+
+	schedule := &Schedule{
+		Id:             id,
+		Payload:        payload,
+		CorrelationId:  msg.correlationId,
+		AutoSplit:      msg.autoSplit,
+		IdempotencyKey: msg.idempotencyKey,
+		At:             at,
+		Cron:           cron,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err = s.store.Put(schedule)
+	if err != nil {
+		return "", err
+	}
+	if s.started {
+		s.arm(schedule)
+	}
+
+	return id, nil
+}
+
+// arm method starts the timer of the schedule, the lock must be held.
+func (s *Scheduler) arm(schedule *Schedule) {
+	if s.stopped {
+		return
+	}
+
+	s.timers[schedule.Id] = time.AfterFunc(time.Until(schedule.At), func() {
+		s.fire(schedule)
+	})
+}
+
+// fire method sends the due message, and arms the next time of the recurring schedule
+// or the retry of the one-time schedule.
+func (s *Scheduler) fire(schedule *Schedule) {
+	s.mu.Lock()
+	if _, found := s.timers[schedule.Id]; !found {
+		// cancelled
+		s.mu.Unlock()
+		return
+	}
+	delete(s.timers, schedule.Id)
+	s.firing[schedule.Id] = false
+	s.mu.Unlock()
+
+	err := s.send(schedule)
+	if err != nil && s.onError != nil {
+		s.onError(schedule, err)
+	}
+
+	storeErr := s.rearm(schedule, err)
+	if storeErr != nil && s.onError != nil {
+		s.onError(schedule, storeErr)
+	}
+
+	if s.onFired != nil {
+		s.onFired(schedule, err)
+	}
+}
+
+// rearm method updates the store after the schedule is fired, unless it is cancelled while firing.
+func (s *Scheduler) rearm(schedule *Schedule, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cancelled := s.firing[schedule.Id]
+	delete(s.firing, schedule.Id)
+	if cancelled {
+		return nil
+	}
+
+	switch {
+	case schedule.Cron != "":
+		next := *schedule
+		return s.reschedule(&next)
+	case err != nil && isRetryable(err) && schedule.Attempts+1 < s.maxAttempts:
+		retry := *schedule
+		retry.Attempts++
+		retry.At = time.Now().Add(backoff(s.minBackoff, s.maxBackoff, retry.Attempts))
+		if err = s.store.Put(&retry); err != nil {
+			return err
+		}
+		s.arm(&retry)
+		return nil
+	default:
+		return s.store.Remove(schedule.Id)
+	}
+}
+
+// reschedule method arms the next time of the recurring schedule, the lock must be held.
+func (s *Scheduler) reschedule(schedule *Schedule) error {
+	cron, err := parseCron(schedule.Cron)
+	if err != nil {
+		_ = s.store.Remove(schedule.Id)
+		return err
+	}
+
+	schedule.At = cron.next(time.Now())
+	if schedule.At.IsZero() {
+		return s.store.Remove(schedule.Id)
+	}
+
+	err = s.store.Put(schedule)
+	if err != nil {
+		return err
+	}
+	s.arm(schedule)
+
+	return nil
+}
+
+// send method sends the message of the schedule, or enqueues it into the outbox.
+func (s *Scheduler) send(schedule *Schedule) error {
+	m, err := s.message.FromPayload(schedule.Payload)
+	if err != nil {
+		return err
+	}
+	msg := m.(builder).build().CorrelationId(schedule.CorrelationId)
+	msg.autoSplit = schedule.AutoSplit
+	msg.idempotencyKey = scheduleKey(schedule)
+
+	if s.outbox != nil {
+		_, err = s.outbox.enqueue(msg)
+		return err
+	}
+
+	resp, err := msg.send(sendOptions{})
+	if err != nil {
+		return err
+	}
+
+	return resp.err()
+}
+
+// scheduleKey method returns the idempotency key of the fire, the schedule id is used if the key is not set.
+// Every fire of the recurring schedule has its own key, so that it is not suppressed as the duplicate of the last fire.
+func scheduleKey(schedule *Schedule) string {
+	key := schedule.IdempotencyKey
+	if key == "" {
+		key = "schedule:" + schedule.Id
+	}
+	if schedule.Cron != "" {
+		key += "@" + schedule.At.UTC().Format(time.RFC3339)
+	}
+	return key
+}
+
+// memoryScheduleStore struct is the in-memory ScheduleStore.
+type memoryScheduleStore struct {
+	mu        sync.Mutex
+	schedules map[string]*Schedule
+}
+
+// NewMemoryScheduleStore method creates a new in-memory ScheduleStore, the schedules are lost when the process exits.
+func NewMemoryScheduleStore() ScheduleStore {
+	return &memoryScheduleStore{
+		schedules: make(map[string]*Schedule),
+	}
+}
+
+// Put method adds or updates the schedule.
+func (s *memoryScheduleStore) Put(schedule *Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *schedule
+	s.schedules[schedule.Id] = &copied
+
+	return nil
+}
+
+// Remove method removes the schedule.
+func (s *memoryScheduleStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.schedules, id)
+
+	return nil
+}
+
+// All method returns all the schedules, ordered by the next time.
+func (s *memoryScheduleStore) All() ([]*Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules := make([]*Schedule, 0, len(s.schedules))
+	for _, schedule := range s.schedules {
+		copied := *schedule
+		schedules = append(schedules, &copied)
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].At.Before(schedules[j].At)
+	})
+
+	return schedules, nil
+}
+
+// fileScheduleStore struct is the ScheduleStore persisted in a local json file.
+type fileScheduleStore struct {
+	*memoryScheduleStore
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileScheduleStore method opens or creates the schedule file.
+// The whole file is rewritten atomically on every change.
+func NewFileScheduleStore(path string) (ScheduleStore, error) {
+	s := &fileScheduleStore{
+		memoryScheduleStore: NewMemoryScheduleStore().(*memoryScheduleStore),
+		path:                path,
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var schedules []*Schedule
+	err = json.Unmarshal(data, &schedules)
+	if err != nil {
+		return nil, err
+	}
+	for _, schedule := range schedules {
+		s.schedules[schedule.Id] = schedule
+	}
+
+	return s, nil
+}
+
+// Put method adds or updates the schedule, the schedule is kept in memory only after the file is saved.
+func (s *fileScheduleStore) Put(schedule *Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules, _ := s.memoryScheduleStore.All()
+	saved := make([]*Schedule, 0, len(schedules)+1)
+	for _, item := range schedules {
+		if item.Id != schedule.Id {
+			saved = append(saved, item)
+		}
+	}
+	saved = append(saved, schedule)
+
+	err := s.save(saved)
+	if err != nil {
+		return err
+	}
+	return s.memoryScheduleStore.Put(schedule)
+}
+
+// Remove method removes the schedule, the schedule is removed from memory only after the file is saved.
+func (s *fileScheduleStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	schedules, _ := s.memoryScheduleStore.All()
+	saved := make([]*Schedule, 0, len(schedules))
+	for _, item := range schedules {
+		if item.Id != id {
+			saved = append(saved, item)
+		}
+	}
+
+	err := s.save(saved)
+	if err != nil {
+		return err
+	}
+	return s.memoryScheduleStore.Remove(id)
+}
+
+// save method rewrites the schedule file with the schedules, the caller must hold the lock.
+func (s *fileScheduleStore) save(schedules []*Schedule) error {
+	data, err := json.Marshal(schedules)
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
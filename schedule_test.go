@@ -0,0 +1,237 @@
+package wxcom_test
+
+import (
+	"github.com/mingzaily/go-wxcom"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler_After(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	fired := make(chan error, 1)
+	store := wxcom.NewMemoryScheduleStore()
+	scheduler := tempWx.NewScheduler(store).OnFired(func(schedule *wxcom.Schedule, err error) {
+		fired <- err
+	})
+	assertEqual(t, scheduler.Start(), nil)
+	defer scheduler.Stop()
+
+	_, err := scheduler.After(10*time.Millisecond, tempWx.M().ToUser([]string{"test"}).Text("测试TEXT"))
+	assertEqual(t, err, nil)
+
+	cancelled, err := scheduler.After(10*time.Millisecond, tempWx.M().ToUser([]string{"test"}).Text("测试TEXT"))
+	assertEqual(t, err, nil)
+	assertEqual(t, scheduler.Cancel(cancelled), nil)
+
+	schedules, _ := store.All()
+	assertEqual(t, len(schedules), 1)
+
+	assertEqual(t, <-fired, nil)
+
+	schedules, _ = store.All()
+	assertEqual(t, len(schedules), 0)
+}
+
+func TestScheduler_Retry(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"access_token\":\"token\",\"expires_in\":7200}"))
+		default:
+			if atomic.AddInt32(&calls, 1) == 1 {
+				_, _ = w.Write([]byte("{\"errcode\":45009,\"errmsg\":\"api freq out of limit\"}"))
+				return
+			}
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"msgid\":\"msgid\"}"))
+		}
+	}))
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	fired := make(chan error, 2)
+	store := wxcom.NewMemoryScheduleStore()
+	scheduler := tempWx.NewScheduler(store).
+		SetBackoff(time.Millisecond, time.Millisecond).
+		OnFired(func(schedule *wxcom.Schedule, err error) {
+			fired <- err
+		})
+	assertEqual(t, scheduler.Start(), nil)
+	defer scheduler.Stop()
+
+	_, err := scheduler.After(time.Millisecond, tempWx.M().ToUser([]string{"test"}).Text("测试TEXT"))
+	assertEqual(t, err, nil)
+
+	// kept after the retryable error
+	assertEqual(t, (<-fired).Error(), "wxcom: errcode 45009, errmsg api freq out of limit")
+	assertEqual(t, <-fired, nil)
+
+	schedules, _ := store.All()
+	assertEqual(t, len(schedules), 0)
+	assertEqual(t, atomic.LoadInt32(&calls), int32(2))
+}
+
+func TestScheduler_Cron(t *testing.T) {
+	store := wxcom.NewMemoryScheduleStore()
+	scheduler := wx.NewScheduler(store)
+
+	_, err := scheduler.Cron("0 9 * * 1-5", wx.M().ToUser([]string{"test"}).Text("测试TEXT"))
+	assertEqual(t, err, nil)
+
+	schedules, _ := store.All()
+	assertEqual(t, len(schedules), 1)
+
+	at := schedules[0].At
+	assertEqual(t, at.After(time.Now()), true)
+	assertEqual(t, at.Hour(), 9)
+	assertEqual(t, at.Minute(), 0)
+	assertEqual(t, at.Weekday() != time.Saturday && at.Weekday() != time.Sunday, true)
+
+	_, err = scheduler.Cron("0 9 * *", wx.M().ToUser([]string{"test"}).Text("测试TEXT"))
+	assertEqual(t, err.Error(), "cron expression \"0 9 * *\" must have 5 fields")
+
+	_, err = scheduler.Cron("60 9 * * *", wx.M().ToUser([]string{"test"}).Text("测试TEXT"))
+	assertEqual(t, err.Error(), "value \"60\" out of range in minute field")
+}
+
+func TestScheduler_CancelWhileFiring(t *testing.T) {
+	sending := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"access_token\":\"token\",\"expires_in\":7200}"))
+		default:
+			sending <- struct{}{}
+			<-release
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"msgid\":\"msgid\"}"))
+		}
+	}))
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	fired := make(chan error, 1)
+	store := wxcom.NewMemoryScheduleStore()
+	scheduler := tempWx.NewScheduler(store).OnFired(func(schedule *wxcom.Schedule, err error) {
+		fired <- err
+	})
+	id, err := scheduler.Cron("* * * * *", tempWx.M().ToUser([]string{"test"}).Text("测试TEXT"))
+	assertEqual(t, err, nil)
+
+	// make the schedule due
+	schedules, _ := store.All()
+	schedules[0].At = time.Now()
+	assertEqual(t, store.Put(schedules[0]), nil)
+
+	assertEqual(t, scheduler.Start(), nil)
+	defer scheduler.Stop()
+
+	<-sending
+	assertEqual(t, scheduler.Cancel(id), nil)
+	close(release)
+
+	assertEqual(t, <-fired, nil)
+
+	schedules, _ = store.All()
+	assertEqual(t, len(schedules), 0)
+}
+
+func TestScheduler_CronDedup(t *testing.T) {
+	ts, payloads := createRecordServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123).SetDeduplicator(wxcom.NewMemoryDedupStore(), time.Hour)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	store := wxcom.NewMemoryScheduleStore()
+	_, err := tempWx.NewScheduler(store).Cron("* * * * *", tempWx.M().ToUser([]string{"test"}).IdempotencyKey("report").Text("测试TEXT"))
+	assertEqual(t, err, nil)
+
+	schedules, _ := store.All()
+	assertEqual(t, schedules[0].IdempotencyKey, "report")
+
+	// every fire of the recurring schedule is sent
+	for i := 0; i < 2; i++ {
+		schedules, _ = store.All()
+		schedules[0].At = time.Now().Add(-time.Duration(i) * time.Minute)
+		assertEqual(t, store.Put(schedules[0]), nil)
+
+		fired := make(chan error, 1)
+		scheduler := tempWx.NewScheduler(store).OnFired(func(schedule *wxcom.Schedule, err error) {
+			fired <- err
+		})
+		assertEqual(t, scheduler.Start(), nil)
+		assertEqual(t, <-fired, nil)
+		scheduler.Stop()
+	}
+
+	assertEqual(t, len(payloads()), 2)
+}
+
+func TestFileScheduleStore_Restart(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	path := filepath.Join(t.TempDir(), "schedules.json")
+
+	store, err := wxcom.NewFileScheduleStore(path)
+	assertEqual(t, err, nil)
+	_, err = tempWx.NewScheduler(store).At(time.Now().Add(-time.Minute), tempWx.M().ToUser([]string{"test"}).Text("测试TEXT"))
+	assertEqual(t, err, nil)
+
+	// restart, the missed schedule is sent once started
+	store, err = wxcom.NewFileScheduleStore(path)
+	assertEqual(t, err, nil)
+	schedules, _ := store.All()
+	assertEqual(t, len(schedules), 1)
+
+	fired := make(chan error, 1)
+	scheduler := tempWx.NewScheduler(store).OnFired(func(schedule *wxcom.Schedule, err error) {
+		fired <- err
+	})
+	assertEqual(t, scheduler.Start(), nil)
+	defer scheduler.Stop()
+
+	assertEqual(t, <-fired, nil)
+
+	store, err = wxcom.NewFileScheduleStore(path)
+	assertEqual(t, err, nil)
+	schedules, _ = store.All()
+	assertEqual(t, len(schedules), 0)
+}
+
+func TestFileScheduleStore_WriteFailed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedules.json")
+
+	store, err := wxcom.NewFileScheduleStore(path)
+	assertEqual(t, err, nil)
+	assertEqual(t, store.Put(&wxcom.Schedule{Id: "1", At: time.Now()}), nil)
+
+	// the temp file cannot be written, the change is not kept in memory
+	assertEqual(t, os.Mkdir(path+".tmp", 0700), nil)
+	assertNotEqual(t, store.Put(&wxcom.Schedule{Id: "2", At: time.Now()}), nil)
+	assertNotEqual(t, store.Remove("1"), nil)
+
+	schedules, _ := store.All()
+	assertEqual(t, len(schedules), 1)
+	assertEqual(t, schedules[0].Id, "1")
+}
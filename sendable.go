@@ -0,0 +1,72 @@
+package wxcom
+
+// Sendable interface is implemented by all the message kinds,
+// such as the ones created by Message.Text, Message.Image and Message.Textcard.
+type Sendable interface {
+	// Send does send the message.
+	Send() (*RespMessage, error)
+	// ToJson returns the message string.
+	ToJson() (string, error)
+	// Validate checks the message against the WeCom limits.
+	Validate() error
+}
+
+// builder interface is implemented by all the message kinds to create the message client.
+type builder interface {
+	build() *Message
+}
+
+// buildMessage method returns the message client of the sendable,
+// the sendable not implemented by this package is reconstructed from ToJson.
+func buildMessage(m *Message, s Sendable) (*Message, error) {
+	if b, ok := s.(builder); ok {
+		return b.build(), nil
+	}
+
+	data, err := s.ToJson()
+	if err != nil {
+		return nil, err
+	}
+
+	reconstructed, err := m.FromJson(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return reconstructed.(builder).build(), nil
+}
+
+var (
+	_ Sendable = (*text)(nil)
+	_ Sendable = (*image)(nil)
+	_ Sendable = (*voice)(nil)
+	_ Sendable = (*video)(nil)
+	_ Sendable = (*file)(nil)
+	_ Sendable = (*textcard)(nil)
+	_ Sendable = (*news)(nil)
+	_ Sendable = (*markdown)(nil)
+	_ Sendable = (*markdownV2)(nil)
+	_ Sendable = (*templateCard)(nil)
+)
+
+// SendAllResult struct holds the result of a message sent by SendAll.
+type SendAllResult struct {
+	Message  Sendable
+	Response *RespMessage
+	Err      error
+}
+
+// SendAll method sends the messages with at most concurrency messages at the same time.
+// The results are in the same order as the messages.
+func SendAll(messages []Sendable, concurrency int) []SendAllResult {
+	results := make([]SendAllResult, len(messages))
+	parallel(len(messages), concurrency, func(i int) {
+		resp, err := messages[i].Send()
+		results[i] = SendAllResult{
+			Message:  messages[i],
+			Response: resp,
+			Err:      err,
+		}
+	})
+	return results
+}
@@ -0,0 +1,32 @@
+package wxcom_test
+
+import (
+	"github.com/mingzaily/go-wxcom"
+	"testing"
+)
+
+func TestSendAll(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	m := tempWx.M().ToUser([]string{"test"})
+	messages := []wxcom.Sendable{
+		m.Text("测试TEXT"),
+		m.Markdown("测试MARKDOWN"),
+		m.Image(""),
+		m.Textcard("标题", "描述", "https://test.com"),
+	}
+
+	results := wxcom.SendAll(messages, 2)
+
+	assertEqual(t, len(results), 4)
+	assertEqual(t, results[0].Err, nil)
+	assertEqual(t, results[0].Response.Msgid, "msgid")
+	assertEqual(t, results[1].Err, nil)
+	assertEqual(t, results[2].Err.Error(), "image.media_id cannot be empty")
+	assertEqual(t, results[2].Message, messages[2])
+	assertEqual(t, results[3].Err, nil)
+}
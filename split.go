@@ -0,0 +1,164 @@
+package wxcom
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// parts method splits the text or markdown message whose content exceeds the WeCom limit into several messages.
+// The parts are numbered as "(1/3)" at the end.
+func (m *Message) parts() []*Message {
+	limit := m.contentLimit()
+
+	if !m.autoSplit || limit == 0 || len(m.content) <= limit {
+		return []*Message{m}
+	}
+
+	// the numbering needs more bytes when there are more parts
+	var contents []string
+	for count := 1; ; {
+		contents = splitContent(m.content, limit-len(partNumber(count, count)), m.msgType == "markdown")
+		if len(partNumber(len(contents), len(contents))) <= len(partNumber(count, count)) {
+			break
+		}
+		count = len(contents)
+	}
+
+	parts := make([]*Message, len(contents))
+	for i, content := range contents {
+		part := m.clone()
+		part.content = content + partNumber(i+1, len(contents))
+		parts[i] = part
+	}
+
+	return parts
+}
+
+// partNumber method returns the numbering of the part.
+func partNumber(i, count int) string {
+	return fmt.Sprintf("\n(%d/%d)", i, count)
+}
+
+// splitContent method splits the content into parts of at most limit bytes,
+// on the line boundaries if possible, otherwise on the rune boundaries.
+// The fenced code blocks of markdown are closed at the end of the part and reopened in the next part.
+func splitContent(content string, limit int, markdown bool) []string {
+	const fence = "```"
+
+	var parts []string
+	var sb strings.Builder
+	inFence := false
+
+	// reserve is the bytes needed to close the fence at the end of the part
+	reserve := func() int {
+		if inFence {
+			return len("\n" + fence)
+		}
+		return 0
+	}
+	flush := func() {
+		part := strings.TrimRight(sb.String(), "\n")
+		if inFence {
+			part += "\n" + fence
+		}
+		if strings.TrimSpace(part) != "" {
+			parts = append(parts, part)
+		}
+		sb.Reset()
+		if inFence {
+			sb.WriteString(fence + "\n")
+		}
+	}
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		isFence := markdown && strings.HasPrefix(strings.TrimSpace(line), fence)
+
+		for line != "" {
+			if sb.Len()+len(line)+reserve() <= limit {
+				sb.WriteString(line)
+				break
+			}
+
+			if sb.Len() > len(fence)+1 || (!inFence && sb.Len() > 0) {
+				flush()
+				continue
+			}
+
+			// the line itself exceeds the limit
+			cut := cutLine(line, limit-sb.Len()-reserve(), markdown && !inFence)
+			sb.WriteString(line[:cut])
+			line = line[cut:]
+			flush()
+		}
+
+		if isFence {
+			inFence = !inFence
+		}
+	}
+
+	if inFence {
+		// the fence opened by the content is not closed, keep it as is
+		inFence = false
+	}
+	if sb.Len() > 0 {
+		flush()
+	}
+
+	return parts
+}
+
+// cutLine method returns the bytes of the line fitting in the size, at least one rune.
+// For markdown, the cut is before a space and outside the inline constructs if possible.
+func cutLine(line string, size int, markdown bool) int {
+	if size >= len(line) {
+		return len(line)
+	}
+
+	end := size
+	for end > 0 && !utf8.RuneStart(line[end]) {
+		end--
+	}
+	if end == 0 {
+		_, end = utf8.DecodeRuneInString(line)
+		return end
+	}
+
+	if !markdown {
+		return end
+	}
+
+	for i := end; i > end/2; i-- {
+		if utf8.RuneStart(line[i]) && (line[i] == ' ' || i == end) && balancedMarkdown(line[:i]) {
+			return i
+		}
+	}
+	for i := end; i > 0; i-- {
+		if utf8.RuneStart(line[i]) && balancedMarkdown(line[:i]) {
+			return i
+		}
+	}
+
+	return end
+}
+
+// balancedMarkdown method reports whether the inline constructs of the markdown are all closed.
+func balancedMarkdown(s string) bool {
+	return strings.Count(s, "**")%2 == 0 &&
+		strings.Count(s, "`")%2 == 0 &&
+		strings.Count(s, "<font") == strings.Count(s, "</font>") &&
+		strings.Count(s, "[") == strings.Count(s, "]") &&
+		strings.LastIndex(s, "](") <= strings.LastIndex(s, ")") &&
+		!unclosedTag(s)
+}
+
+// unclosedTag method reports whether the markdown ends inside a tag, such as `<font color="info"`.
+func unclosedTag(s string) bool {
+	i := strings.LastIndex(s, "<")
+	if i < 0 || i < strings.LastIndex(s, ">") {
+		return false
+	}
+
+	rest := s[i+1:]
+	return rest == "" || rest[0] == '/' || 'a' <= rest[0] && rest[0] <= 'z' || 'A' <= rest[0] && rest[0] <= 'Z'
+}
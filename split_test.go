@@ -0,0 +1,106 @@
+package wxcom_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mingzaily/go-wxcom"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func splitParts(t *testing.T, m wxcom.Sendable) []string {
+	data, err := m.ToJson()
+	assertEqual(t, err, nil)
+
+	var payloads []wxcom.MessagePayload
+	assertEqual(t, json.Unmarshal([]byte(data), &payloads), nil)
+
+	var contents []string
+	for _, payload := range payloads {
+		if payload.Text != nil {
+			contents = append(contents, payload.Text.Content)
+		} else {
+			contents = append(contents, payload.Markdown.Content)
+		}
+	}
+	return contents
+}
+
+func TestText_SetAutoSplit(t *testing.T) {
+	var lines []string
+	for i := 0; i < 300; i++ {
+		lines = append(lines, fmt.Sprintf("log line %03d", i))
+	}
+	content := strings.Join(lines, "\n")
+
+	_, err := msg.Clone().ToUser([]string{"test"}).Text(content).ToJson()
+	assertEqual(t, err.Error(), "text.content cannot exceed 2048 bytes")
+
+	parts := splitParts(t, msg.Clone().ToUser([]string{"test"}).Text(content).SetAutoSplit(true))
+	assertEqual(t, len(parts), 2)
+
+	var joined []string
+	for i, part := range parts {
+		assertEqual(t, len(part) <= 2048, true)
+		suffix := fmt.Sprintf("\n(%d/2)", i+1)
+		assertEqual(t, strings.HasSuffix(part, suffix), true)
+		joined = append(joined, strings.TrimSuffix(part, suffix))
+	}
+	assertEqual(t, strings.Join(joined, "\n"), content)
+
+	// not split
+	assertJson(t, msg.Clone().ToUser([]string{"test"}).Text("测试TEXT").SetAutoSplit(true),
+		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"text\",\"safe\":0,\"text\":{\"content\":\"测试TEXT\"},\"touser\":\"test\"}")
+}
+
+func TestText_SetAutoSplit_Runes(t *testing.T) {
+	content := strings.Repeat("测", 1500)
+
+	parts := splitParts(t, msg.Clone().ToUser([]string{"test"}).Text(content).SetAutoSplit(true))
+	assertEqual(t, len(parts), 3)
+	for _, part := range parts {
+		assertEqual(t, utf8.ValidString(part), true)
+		assertEqual(t, len(part) <= 2048, true)
+	}
+}
+
+func TestMarkdown_SetAutoSplit(t *testing.T) {
+	content := "# 日志\n```\n" + strings.Repeat("code line\n", 300) + "```\n" + strings.Repeat("**粗体** <font color=\"info\">绿色</font> ", 100)
+
+	parts := splitParts(t, msg.Clone().ToUser([]string{"test"}).Markdown(content).SetAutoSplit(true))
+	assertEqual(t, len(parts) > 2, true)
+
+	for i, part := range parts {
+		assertEqual(t, len(part) <= 2048, true)
+		assertEqual(t, strings.Count(part, "```")%2, 0)
+		assertEqual(t, strings.Count(part, "**")%2, 0)
+		assertEqual(t, strings.Count(part, "<font"), strings.Count(part, "</font>"))
+		assertEqual(t, strings.HasSuffix(part, fmt.Sprintf("\n(%d/%d)", i+1, len(parts))), true)
+	}
+}
+
+func TestMarkdown_SetAutoSplit_CJKFont(t *testing.T) {
+	content := strings.Repeat("服务异常<font color=\"warning\">告警</font>", 200)
+
+	parts := splitParts(t, msg.Clone().ToUser([]string{"test"}).Markdown(content).SetAutoSplit(true))
+	assertEqual(t, len(parts) > 1, true)
+
+	for _, part := range parts {
+		assertEqual(t, len(part) <= 2048, true)
+		assertEqual(t, strings.Count(part, "<"), strings.Count(part, ">"))
+		assertEqual(t, strings.Count(part, "<font"), strings.Count(part, "</font>"))
+	}
+}
+
+func TestText_SetAutoSplit_Send(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.M().ToUser([]string{"test"}).Text(strings.Repeat("a\n", 2000)).SetAutoSplit(true).Send()
+	assertEqual(t, err, nil)
+	assertEqual(t, len(resp.Msgids), 2)
+}
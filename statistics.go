@@ -0,0 +1,50 @@
+package wxcom
+
+import "errors"
+
+const (
+	// StatisticsToday queries the statistics of today.
+	StatisticsToday = 0
+	// StatisticsYesterday queries the statistics of yesterday.
+	StatisticsYesterday = 1
+)
+
+// RespStatistics struct holds response values of get message statistics.
+type RespStatistics struct {
+	respCommon
+	Statistics []StatisticsItem `json:"statistics"`
+}
+
+// StatisticsItem struct holds the message statistics of an agent.
+type StatisticsItem struct {
+	Agentid int    `json:"agentid"`
+	AppName string `json:"app_name"`
+	Count   int    `json:"count"`
+}
+
+// Agent method returns the statistics of the agent, the second value reports whether it was found.
+func (r *RespStatistics) Agent(agentid int) (StatisticsItem, bool) {
+	for _, item := range r.Statistics {
+		if item.Agentid == agentid {
+			return item, true
+		}
+	}
+	return StatisticsItem{}, false
+}
+
+// GetMessageStatistics method get the count of messages sent by each agent.
+// Param timeType is StatisticsToday or StatisticsYesterday.
+func (w *Wxcom) GetMessageStatistics(timeType int) (*RespStatistics, error) {
+	response := &RespStatistics{}
+
+	if timeType != StatisticsToday && timeType != StatisticsYesterday {
+		return nil, errors.New("unsupported time type")
+	}
+
+	err := w.sendWithRetry("/cgi-bin/message/get_statistics", nil, map[string]interface{}{"time_type": timeType}, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
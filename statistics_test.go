@@ -0,0 +1,28 @@
+package wxcom_test
+
+import (
+	"github.com/mingzaily/go-wxcom"
+	"testing"
+)
+
+func TestWxcom_GetMessageStatistics(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.GetMessageStatistics(wxcom.StatisticsToday)
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+
+	item, found := resp.Agent(123)
+	assertEqual(t, found, true)
+	assertEqual(t, item, wxcom.StatisticsItem{Agentid: 123, AppName: "app", Count: 10})
+
+	_, found = resp.Agent(1)
+	assertEqual(t, found, false)
+
+	_, err = tempWx.GetMessageStatistics(2)
+	assertEqual(t, err.Error(), "unsupported time type")
+}
@@ -0,0 +1,63 @@
+package wxcom
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// messageSendPath is the path of sending message by the agent.
+	messageSendPath = "/cgi-bin/message/send"
+
+	// targetAgent is the message sent to the members of the corp by the agent.
+	targetAgent = "agent"
+	// targetAppchat is the message sent to the appchat created by the agent.
+	targetAppchat = "appchat"
+	// targetRobot is the message sent by the webhook of the group robot.
+	targetRobot = "robot"
+	// targetLinkedcorp is the message sent to the members of the linked corps by the agent.
+	targetLinkedcorp = "linkedcorp"
+)
+
+// messageTarget struct holds where the message is sent,
+// it is set by the method creating the message, such as Wxcom.M, Wxcom.Chat, Wxcom.Linkedcorp and Robot.M.
+type messageTarget struct {
+	// kind is targetAgent, targetAppchat, targetRobot or targetLinkedcorp.
+	kind   string
+	path   string
+	chatid string
+	robot  robotSender
+}
+
+// agentTarget method returns the target of the message sent by the agent.
+func agentTarget() messageTarget {
+	return messageTarget{kind: targetAgent, path: messageSendPath}
+}
+
+// appchatTarget method returns the target of the message sent to the appchat.
+func appchatTarget(chatid string) messageTarget {
+	return messageTarget{kind: targetAppchat, path: appchatSendPath, chatid: chatid}
+}
+
+// robotTarget method returns the target of the message sent by the robot or the robot pool.
+func robotTarget(robot robotSender) messageTarget {
+	return messageTarget{kind: targetRobot, path: webhookSendPath, robot: robot}
+}
+
+// linkedcorpTarget method returns the target of the message sent to the linked corps.
+func linkedcorpTarget() messageTarget {
+	return messageTarget{kind: targetLinkedcorp, path: linkedcorpSendPath}
+}
+
+// sender method returns who sends the message, the webhook keys of robot, the corpid and chatid of appchat,
+// or the corpid and agentid of the agent and linkedcorp.
+func (t messageTarget) sender(wx *Wxcom) string {
+	switch t.kind {
+	case targetRobot:
+		return "robot:" + strings.Join(t.robot.keys(), "|")
+	case targetAppchat:
+		return fmt.Sprintf("appchat:%s:%s", wx.corpid, t.chatid)
+	default:
+		return fmt.Sprintf("%s:%s:%d", t.kind, wx.corpid, wx.agentid)
+	}
+}
@@ -0,0 +1,276 @@
+package wxcom
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// TemplateRegistry struct holds the named message templates of each locale.
+//
+// The templates use text/template. The kind of the template decides how it is rendered:
+//
+//	text, markdown: the whole template is the content.
+//	textcard:       the templates "title", "description", "url" and optional "btntxt" are the fields.
+//	news:           the templates "title", "description", "url" and optional "picurl" are the fields of one article,
+//	                or the template "articles" renders the json array of the articles.
+//
+// The data is rendered as is, the templates can escape it by the functions:
+//
+//	json:     renders the value as json, such as {"title": {{json .Title}}} in the template "articles".
+//	markdown: escapes the text to be displayed as is in markdown, see EscapeMarkdown.
+type TemplateRegistry struct {
+	mu            sync.RWMutex
+	templates     map[string]map[string]*messageTemplate
+	fallbacks     map[string][]string
+	defaultLocale string
+}
+
+// messageTemplate struct holds a parsed template.
+type messageTemplate struct {
+	kind string
+	tmpl *template.Template
+}
+
+// templateFuncs holds the functions of the templates to escape the data.
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"markdown": EscapeMarkdown,
+}
+
+// NewTemplateRegistry method creates a new TemplateRegistry instance.
+func NewTemplateRegistry() *TemplateRegistry {
+	return &TemplateRegistry{
+		templates: make(map[string]map[string]*messageTemplate),
+		fallbacks: make(map[string][]string),
+	}
+}
+
+// SetDefaultLocale method sets the locale used when the template is not found in the requested locale.
+func (r *TemplateRegistry) SetDefaultLocale(locale string) *TemplateRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.defaultLocale = locale
+	return r
+}
+
+// SetFallback method sets the locales tried in order when the template is not found in the locale.
+// By default, "zh_CN" falls back to "zh", then the default locale, then the templates without locale.
+func (r *TemplateRegistry) SetFallback(locale string, fallbacks ...string) *TemplateRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fallbacks[locale] = fallbacks
+	return r
+}
+
+// Register method parses and registers the template of the name, locale and kind.
+// The kind is one of "text", "markdown", "textcard" and "news".
+func (r *TemplateRegistry) Register(name, locale, kind, text string) error {
+	switch kind {
+	case "text", "markdown", "textcard", "news":
+	default:
+		return fmt.Errorf("unsupported template kind %q", kind)
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.templates[name] == nil {
+		r.templates[name] = make(map[string]*messageTemplate)
+	}
+	r.templates[name][locale] = &messageTemplate{kind: kind, tmpl: tmpl}
+
+	return nil
+}
+
+// LoadDir method loads the templates from the directory.
+//
+// The files are named as "<name>.<kind>.tmpl". The files in the sub directories are the templates of the locale
+// named by the sub directory, such as "zh_CN/deploy_done.markdown.tmpl",
+// and the files in the directory itself are the templates without locale.
+func (r *TemplateRegistry) LoadDir(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".tmpl") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		locale := filepath.ToSlash(filepath.Dir(rel))
+		if locale == "." {
+			locale = ""
+		}
+
+		parts := strings.Split(strings.TrimSuffix(info.Name(), ".tmpl"), ".")
+		if len(parts) != 2 {
+			return fmt.Errorf("template file %q must be named as <name>.<kind>.tmpl", path)
+		}
+
+		text, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return r.Register(parts[0], locale, parts[1], string(text))
+	})
+}
+
+// lookup method finds the template by the fallback chain of the locale.
+func (r *TemplateRegistry) lookup(name, locale string) (*messageTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	locales, found := r.templates[name]
+	if !found {
+		return nil, fmt.Errorf("template %q is not found", name)
+	}
+
+	for _, candidate := range r.localeChain(locale) {
+		if tmpl, found := locales[candidate]; found {
+			return tmpl, nil
+		}
+	}
+
+	return nil, fmt.Errorf("template %q is not found in locale %q", name, locale)
+}
+
+// localeChain method returns the locales tried in order, the lock must be held.
+func (r *TemplateRegistry) localeChain(locale string) []string {
+	chain := []string{locale}
+	if fallbacks, found := r.fallbacks[locale]; found {
+		chain = append(chain, fallbacks...)
+	} else if i := strings.IndexAny(locale, "_-"); i > 0 {
+		chain = append(chain, locale[:i])
+	}
+	return append(chain, r.defaultLocale, "")
+}
+
+// Render method renders the template into the message kind of the template.
+func (r *TemplateRegistry) Render(m *Message, name, locale string, data interface{}) (Sendable, error) {
+	tmpl, err := r.lookup(name, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	switch tmpl.kind {
+	case "text":
+		content, err := tmpl.execute("", data)
+		if err != nil {
+			return nil, err
+		}
+		return m.Text(content), nil
+	case "markdown":
+		content, err := tmpl.execute("", data)
+		if err != nil {
+			return nil, err
+		}
+		return m.Markdown(content), nil
+	case "textcard":
+		fields, err := tmpl.executeFields(data, "title", "description", "url", "btntxt")
+		if err != nil {
+			return nil, err
+		}
+		return m.Textcard(fields[0], fields[1], fields[2]).SetBtnTxt(fields[3]), nil
+	default:
+		articles, err := tmpl.executeArticles(data)
+		if err != nil {
+			return nil, err
+		}
+		return m.News(articles), nil
+	}
+}
+
+// execute method executes the named template, the whole template if the name is empty.
+func (t *messageTemplate) execute(name string, data interface{}) (string, error) {
+	var sb strings.Builder
+
+	var err error
+	if name == "" {
+		err = t.tmpl.Execute(&sb, data)
+	} else {
+		err = t.tmpl.ExecuteTemplate(&sb, name, data)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// executeFields method executes the named templates, the ones not defined are empty.
+func (t *messageTemplate) executeFields(data interface{}, names ...string) ([]string, error) {
+	fields := make([]string, len(names))
+	for i, name := range names {
+		if t.tmpl.Lookup(name) == nil {
+			continue
+		}
+		field, err := t.execute(name, data)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = field
+	}
+	return fields, nil
+}
+
+// executeArticles method executes the articles of the news template.
+func (t *messageTemplate) executeArticles(data interface{}) ([]NewsArticle, error) {
+	if t.tmpl.Lookup("articles") != nil {
+		content, err := t.execute("articles", data)
+		if err != nil {
+			return nil, err
+		}
+
+		var articles []NewsArticle
+		err = json.Unmarshal([]byte(content), &articles)
+		if err != nil {
+			return nil, err
+		}
+		return articles, nil
+	}
+
+	fields, err := t.executeFields(data, "title", "description", "url", "picurl")
+	if err != nil {
+		return nil, err
+	}
+	if fields[0] == "" {
+		return nil, errors.New("news template must define \"title\" or \"articles\"")
+	}
+
+	return []NewsArticle{{Title: fields[0], Description: fields[1], Url: fields[2], Picurl: fields[3]}}, nil
+}
+
+// SetTemplates method sets the template registry used by Message.RenderTemplate.
+func (w *Wxcom) SetTemplates(templates *TemplateRegistry) *Wxcom {
+	w.templates = templates
+	return w
+}
+
+// RenderTemplate method renders the named template of the locale into message, see TemplateRegistry.
+func (m *Message) RenderTemplate(name, locale string, data interface{}) (Sendable, error) {
+	if m.wx.templates == nil {
+		return nil, errors.New("template registry is not set")
+	}
+	return m.wx.templates.Render(m, name, locale, data)
+}
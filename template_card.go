@@ -0,0 +1,268 @@
+package wxcom
+
+import "errors"
+
+// TemplateCard struct holds the template card payload.
+//
+// CardType is one of "text_notice", "news_notice", "button_interaction",
+// "vote_interaction" and "multiple_interaction". Only the fields used by the card type need to be set.
+type TemplateCard struct {
+	CardType              string                  `json:"card_type"`
+	Source                *CardSource             `json:"source,omitempty"`
+	ActionMenu            *CardActionMenu         `json:"action_menu,omitempty"`
+	TaskId                string                  `json:"task_id,omitempty"`
+	MainTitle             *CardMainTitle          `json:"main_title,omitempty"`
+	QuoteArea             *CardQuoteArea          `json:"quote_area,omitempty"`
+	EmphasisContent       *CardEmphasisContent    `json:"emphasis_content,omitempty"`
+	SubTitleText          string                  `json:"sub_title_text,omitempty"`
+	HorizontalContentList []CardHorizontalContent `json:"horizontal_content_list,omitempty"`
+	JumpList              []CardJump              `json:"jump_list,omitempty"`
+	CardAction            *CardAction             `json:"card_action,omitempty"`
+	ImageTextArea         *CardImageTextArea      `json:"image_text_area,omitempty"`
+	CardImage             *CardImage              `json:"card_image,omitempty"`
+	VerticalContentList   []CardVerticalContent   `json:"vertical_content_list,omitempty"`
+	ButtonSelection       *CardSelection          `json:"button_selection,omitempty"`
+	ButtonList            []CardButton            `json:"button_list,omitempty"`
+	Checkbox              *CardCheckbox           `json:"checkbox,omitempty"`
+	SelectList            []CardSelection         `json:"select_list,omitempty"`
+	SubmitButton          *CardSubmitButton       `json:"submit_button,omitempty"`
+	ReplaceText           string                  `json:"replace_text,omitempty"`
+	Feedback              *CardFeedback           `json:"feedback,omitempty"`
+}
+
+// CardSource struct holds the source of the template card.
+type CardSource struct {
+	IconUrl   string `json:"icon_url,omitempty"`
+	Desc      string `json:"desc,omitempty"`
+	DescColor int    `json:"desc_color,omitempty"`
+}
+
+// CardActionMenu struct holds the action menu in the upper right corner of the template card.
+type CardActionMenu struct {
+	Desc       string           `json:"desc,omitempty"`
+	ActionList []CardActionItem `json:"action_list"`
+}
+
+// CardActionItem struct holds an item of the action menu.
+type CardActionItem struct {
+	Text string `json:"text"`
+	Key  string `json:"key"`
+}
+
+// CardMainTitle struct holds the main title of the template card.
+type CardMainTitle struct {
+	Title string `json:"title,omitempty"`
+	Desc  string `json:"desc,omitempty"`
+}
+
+// CardQuoteArea struct holds the quote area of the template card.
+type CardQuoteArea struct {
+	Type      int    `json:"type,omitempty"`
+	Url       string `json:"url,omitempty"`
+	Appid     string `json:"appid,omitempty"`
+	Pagepath  string `json:"pagepath,omitempty"`
+	Title     string `json:"title,omitempty"`
+	QuoteText string `json:"quote_text,omitempty"`
+}
+
+// CardEmphasisContent struct holds the emphasis content of the template card.
+type CardEmphasisContent struct {
+	Title string `json:"title,omitempty"`
+	Desc  string `json:"desc,omitempty"`
+}
+
+// CardHorizontalContent struct holds a line of the horizontal content list.
+type CardHorizontalContent struct {
+	Type    int    `json:"type,omitempty"`
+	Keyname string `json:"keyname"`
+	Value   string `json:"value,omitempty"`
+	Url     string `json:"url,omitempty"`
+	MediaId string `json:"media_id,omitempty"`
+	Userid  string `json:"userid,omitempty"`
+}
+
+// CardJump struct holds a jump link of the template card.
+type CardJump struct {
+	Type     int    `json:"type,omitempty"`
+	Title    string `json:"title"`
+	Url      string `json:"url,omitempty"`
+	Appid    string `json:"appid,omitempty"`
+	Pagepath string `json:"pagepath,omitempty"`
+}
+
+// CardAction struct holds the click action of the whole template card.
+type CardAction struct {
+	Type     int    `json:"type"`
+	Url      string `json:"url,omitempty"`
+	Appid    string `json:"appid,omitempty"`
+	Pagepath string `json:"pagepath,omitempty"`
+}
+
+// CardImageTextArea struct holds the image text area of the news notice card.
+type CardImageTextArea struct {
+	Type     int    `json:"type,omitempty"`
+	Url      string `json:"url,omitempty"`
+	Appid    string `json:"appid,omitempty"`
+	Pagepath string `json:"pagepath,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Desc     string `json:"desc,omitempty"`
+	ImageUrl string `json:"image_url"`
+}
+
+// CardImage struct holds the image of the news notice card.
+type CardImage struct {
+	Url         string  `json:"url"`
+	AspectRatio float64 `json:"aspect_ratio,omitempty"`
+}
+
+// CardVerticalContent struct holds a line of the vertical content list.
+type CardVerticalContent struct {
+	Title string `json:"title"`
+	Desc  string `json:"desc,omitempty"`
+}
+
+// CardSelection struct holds a drop-down selection of the interaction card.
+type CardSelection struct {
+	QuestionKey string       `json:"question_key"`
+	Title       string       `json:"title,omitempty"`
+	Disable     bool         `json:"disable,omitempty"`
+	SelectedId  string       `json:"selected_id,omitempty"`
+	OptionList  []CardOption `json:"option_list"`
+}
+
+// CardOption struct holds an option of the selection or checkbox.
+type CardOption struct {
+	Id        string `json:"id"`
+	Text      string `json:"text"`
+	IsChecked bool   `json:"is_checked,omitempty"`
+}
+
+// CardButton struct holds a button of the button interaction card.
+type CardButton struct {
+	Type  int    `json:"type,omitempty"`
+	Text  string `json:"text"`
+	Style int    `json:"style,omitempty"`
+	Key   string `json:"key,omitempty"`
+	Url   string `json:"url,omitempty"`
+}
+
+// CardCheckbox struct holds the checkbox of the vote interaction card.
+type CardCheckbox struct {
+	QuestionKey string       `json:"question_key"`
+	OptionList  []CardOption `json:"option_list"`
+	Disable     bool         `json:"disable,omitempty"`
+	Mode        int          `json:"mode,omitempty"`
+}
+
+// CardSubmitButton struct holds the submit button of the interaction card.
+type CardSubmitButton struct {
+	Text string `json:"text"`
+	Key  string `json:"key"`
+}
+
+// CardFeedback struct holds the feedback info used when the card is updated.
+type CardFeedback struct {
+	Id string `json:"id"`
+}
+
+// TemplateCardUpdate struct is used to update the template card after user interaction.
+type TemplateCardUpdate struct {
+	wx           *Wxcom
+	path         string
+	responseCode string
+	userids      []string
+	partyids     []int
+	tagids       []int
+	atall        int
+}
+
+// RespTemplateCardUpdate struct holds response values of update template card.
+type RespTemplateCardUpdate struct {
+	respCommon
+	Invaliduser []string `json:"invaliduser"`
+}
+
+// ToUser method sets the users whose card will be updated.
+func (u *TemplateCardUpdate) ToUser(userList []string) *TemplateCardUpdate {
+	u.userids = userList
+	return u
+}
+
+// ToParty method sets the parties whose card will be updated.
+func (u *TemplateCardUpdate) ToParty(partyList []int) *TemplateCardUpdate {
+	u.partyids = partyList
+	return u
+}
+
+// ToTag method sets the tags whose card will be updated.
+func (u *TemplateCardUpdate) ToTag(tagList []int) *TemplateCardUpdate {
+	u.tagids = tagList
+	return u
+}
+
+// ToAll method updates the card of all the users who received it.
+func (u *TemplateCardUpdate) ToAll() *TemplateCardUpdate {
+	u.atall = 1
+	return u
+}
+
+// genRequestParam method generate http request params.
+func (u *TemplateCardUpdate) genRequestParam() (map[string]interface{}, error) {
+	if u.responseCode == "" {
+		return nil, errors.New("responseCode cannot be empty")
+	}
+
+	if len(u.userids) == 0 && len(u.partyids) == 0 && len(u.tagids) == 0 && u.atall == 0 {
+		return nil, errors.New("userids, partyids, tagids, atall cannot be empty at the same time")
+	}
+
+	body := map[string]interface{}{
+		"agentid":       u.wx.agentid,
+		"response_code": u.responseCode,
+	}
+	if len(u.userids) != 0 {
+		body["userids"] = u.userids
+	}
+	if len(u.partyids) != 0 {
+		body["partyids"] = u.partyids
+	}
+	if len(u.tagids) != 0 {
+		body["tagids"] = u.tagids
+	}
+	if u.atall != 0 {
+		body["atall"] = u.atall
+	}
+
+	return body, nil
+}
+
+// send method does send the update request.
+func (u *TemplateCardUpdate) send(key string, value interface{}) (*RespTemplateCardUpdate, error) {
+	response := &RespTemplateCardUpdate{}
+
+	body, err := u.genRequestParam()
+	if err != nil {
+		return nil, err
+	}
+	body[key] = value
+
+	err = u.wx.sendWithRetry(u.path, nil, body, response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ReplaceButton method updates the button of the card to the unclickable state with the given name.
+func (u *TemplateCardUpdate) ReplaceButton(replaceName string) (*RespTemplateCardUpdate, error) {
+	return u.send("button", map[string]string{"replace_name": replaceName})
+}
+
+// ReplaceCard method replaces the whole card with the given template card.
+func (u *TemplateCardUpdate) ReplaceCard(card *TemplateCard) (*RespTemplateCardUpdate, error) {
+	if card == nil {
+		return nil, errors.New("template card cannot be empty")
+	}
+	return u.send("template_card", card)
+}
@@ -0,0 +1,59 @@
+package wxcom_test
+
+import (
+	"github.com/mingzaily/go-wxcom"
+	"testing"
+)
+
+func TestMessage_TemplateCard(t *testing.T) {
+	m := msg.Clone().ToUser([]string{"test"}).TemplateCard(&wxcom.TemplateCard{
+		CardType:  "text_notice",
+		MainTitle: &wxcom.CardMainTitle{Title: "标题"},
+		CardAction: &wxcom.CardAction{
+			Type: 1,
+			Url:  "https://test.com",
+		},
+	})
+
+	assertJson(t, m,
+		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"template_card\",\"template_card\":{\"card_type\":\"text_notice\",\"main_title\":{\"title\":\"标题\"},\"card_action\":{\"type\":1,\"url\":\"https://test.com\"}},\"touser\":\"test\"}")
+}
+
+func TestTemplateCardUpdate_NotToPeople(t *testing.T) {
+	_, err := wx.NewTemplateCardUpdate("code").ReplaceButton("已处理")
+
+	assertEqual(t, err.Error(), "userids, partyids, tagids, atall cannot be empty at the same time")
+}
+
+func TestTemplateCardUpdate_ReplaceButton(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.NewTemplateCardUpdate("code").ToUser([]string{"test"}).ReplaceButton("已处理")
+
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+	assertEqual(t, resp.Invaliduser, []string{"invalid_user"})
+}
+
+func TestTemplateCardUpdate_ReplaceCard(t *testing.T) {
+	ts := createTestServer(t)
+	defer ts.Close()
+
+	tempWx := wxcom.New("123", "321", 123)
+	tempWx.Resty.SetBaseURL(ts.URL)
+
+	resp, err := tempWx.NewTemplateCardUpdate("code").ToAll().ReplaceCard(&wxcom.TemplateCard{
+		CardType:    "button_interaction",
+		ReplaceText: "已处理",
+	})
+
+	assertEqual(t, err, nil)
+	assertEqual(t, resp.Errcode, 0)
+
+	_, err = tempWx.NewTemplateCardUpdate("code").ToAll().ReplaceCard(nil)
+	assertEqual(t, err.Error(), "template card cannot be empty")
+}
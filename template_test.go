@@ -0,0 +1,73 @@
+package wxcom_test
+
+import (
+	"github.com/mingzaily/go-wxcom"
+	"testing"
+)
+
+func TestTemplateRegistry_LoadDir(t *testing.T) {
+	templates := wxcom.NewTemplateRegistry().SetDefaultLocale("en")
+	assertEqual(t, templates.LoadDir("testdata/templates"), nil)
+
+	tempWx := wxcom.New("123", "321", 123).SetTemplates(templates)
+	data := map[string]string{"Service": "api", "Version": "v1.0.0", "Week": "42"}
+
+	m, err := tempWx.M().ToUser([]string{"test"}).RenderTemplate("deploy_done", "zh_CN", data)
+	assertEqual(t, err, nil)
+	assertJson(t, m,
+		"{\"agentid\":123,\"markdown\":{\"content\":\"## 发布完成\\napi v1.0.0\"},\"msgtype\":\"markdown\",\"touser\":\"test\"}")
+
+	// falls back to the template without locale
+	m, err = tempWx.M().ToUser([]string{"test"}).RenderTemplate("deploy_done", "ja_JP", data)
+	assertEqual(t, err, nil)
+	assertJson(t, m,
+		"{\"agentid\":123,\"markdown\":{\"content\":\"## Deploy done\\napi v1.0.0\"},\"msgtype\":\"markdown\",\"touser\":\"test\"}")
+
+	// falls back from zh_CN to zh
+	m, err = tempWx.M().ToUser([]string{"test"}).RenderTemplate("deploy_failed", "zh_CN", data)
+	assertEqual(t, err, nil)
+	assertJson(t, m,
+		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"textcard\",\"textcard\":{\"btntxt\":\"详情\",\"description\":\"api v1.0.0\",\"title\":\"发布失败\",\"url\":\"https://ci.test/api\"},\"touser\":\"test\"}")
+
+	// falls back to the default locale
+	m, err = tempWx.M().ToUser([]string{"test"}).RenderTemplate("weekly", "zh_CN", data)
+	assertEqual(t, err, nil)
+	assertJson(t, m,
+		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"news\",\"news\":{\"articles\":[{\"title\":\"Weekly report\",\"url\":\"https://report.test/42\"}]},\"touser\":\"test\"}")
+
+	_, err = tempWx.M().RenderTemplate("deploy_failed", "en", data)
+	assertEqual(t, err.Error(), "template \"deploy_failed\" is not found in locale \"en\"")
+}
+
+func TestTemplateRegistry_Register(t *testing.T) {
+	templates := wxcom.NewTemplateRegistry().SetFallback("zh_TW", "zh_CN")
+
+	assertEqual(t, templates.Register("hello", "zh_CN", "text", "你好 {{.}}"), nil)
+	assertEqual(t, templates.Register("hello", "", "music", "hello").Error(), "unsupported template kind \"music\"")
+
+	m, err := templates.Render(msg.Clone().ToUser([]string{"test"}), "hello", "zh_TW", "test")
+	assertEqual(t, err, nil)
+	assertJson(t, m,
+		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"text\",\"safe\":0,\"text\":{\"content\":\"你好 test\"},\"touser\":\"test\"}")
+
+	_, err = wx.M().RenderTemplate("hello", "zh_CN", nil)
+	assertEqual(t, err.Error(), "template registry is not set")
+}
+
+func TestTemplateRegistry_Escape(t *testing.T) {
+	templates := wxcom.NewTemplateRegistry()
+	data := map[string]string{"Title": "say \"hi\"\n", "Url": "https://report.test/?a=1&b=2"}
+
+	assertEqual(t, templates.Register("report", "", "news",
+		`{{define "articles"}}[{"title":{{json .Title}},"url":{{json .Url}}}]{{end}}`), nil)
+	m, err := templates.Render(msg.Clone().ToUser([]string{"test"}), "report", "", data)
+	assertEqual(t, err, nil)
+	assertJson(t, m,
+		"{\"agentid\":123,\"enable_id_trans\":0,\"msgtype\":\"news\",\"news\":{\"articles\":[{\"title\":\"say \\\"hi\\\"\\n\",\"url\":\"https://report.test/?a=1\\u0026b=2\"}]},\"touser\":\"test\"}")
+
+	assertEqual(t, templates.Register("alert", "", "markdown", "**{{markdown .Title}}**"), nil)
+	m, err = templates.Render(msg.Clone().ToUser([]string{"test"}), "alert", "", map[string]string{"Title": "*a*"})
+	assertEqual(t, err, nil)
+	assertJson(t, m,
+		"{\"agentid\":123,\"markdown\":{\"content\":\"**\\\\*a\\\\***\"},\"msgtype\":\"markdown\",\"touser\":\"test\"}")
+}
@@ -0,0 +1,301 @@
+package wxcom
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxTextContentBytes       = 2048
+	maxMarkdownContentBytes   = 2048
+	maxTitleBytes             = 128
+	maxDescriptionBytes       = 512
+	maxBtnTxtChars            = 4
+	maxDuplicateCheckInterval = 4 * 60 * 60
+	maxNewsArticles           = 8
+
+	maxRobotMarkdownContentBytes = 4096
+	maxRobotImageBytes           = 2 * 1024 * 1024
+)
+
+// FieldError struct holds the validation error of a field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError struct holds all the validation errors of a message.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Error method implements the error interface.
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldError := range e.Errors {
+		messages[i] = fieldError.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// add method adds a validation error of the field.
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err method returns nil if there is no validation error.
+func (e *ValidationError) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// validate method checks the message against the WeCom limits.
+// The recipients exceeding the limits of one request are allowed, since they are sent in batches.
+func (m *Message) validate() error {
+	v := &ValidationError{}
+	m.validateRecipients(v)
+	m.validateOptions(v)
+	m.validateContent(v)
+	return v.err()
+}
+
+// validateSingleRequest method checks the message, and the recipients must be sent in one request.
+func (m *Message) validateSingleRequest() error {
+	v := &ValidationError{}
+	m.validateRecipients(v)
+	if len(m.toUser) > maxUsersPerSend {
+		v.add("touser", "touser cannot exceed %d users", maxUsersPerSend)
+	}
+	if len(m.toParty) > maxPartiesPerSend {
+		v.add("toparty", "toparty cannot exceed %d parties", maxPartiesPerSend)
+	}
+	if len(m.toTag) > maxTagsPerSend {
+		v.add("totag", "totag cannot exceed %d tags", maxTagsPerSend)
+	}
+	m.validateOptions(v)
+	m.validateContent(v)
+	return v.err()
+}
+
+// validateRecipients method checks the recipients of the message.
+func (m *Message) validateRecipients(v *ValidationError) {
+	if m.target.kind == targetRobot {
+		if !m.recipients().Empty() {
+			v.add("touser", "toUser, toParty, toTag must be empty when sending by robot")
+		}
+		return
+	}
+	if m.target.kind == targetAppchat {
+		if !m.recipients().Empty() {
+			v.add("chatid", "toUser, toParty, toTag must be empty when sending to appchat")
+		}
+		if m.target.chatid == "" {
+			v.add("chatid", "chatid cannot be empty")
+		} else if err := validateChatid(m.target.chatid); err != nil {
+			v.add("chatid", err.Error())
+		}
+		return
+	}
+	if len(m.toUser) == 0 && len(m.toParty) == 0 && len(m.toTag) == 0 {
+		v.add("touser", "toUser, toParty, toTag cannot be empty at the same time")
+	}
+	validateIds(v, "touser", m.toUser)
+	if m.target.kind == targetLinkedcorp && !(len(m.toUser) == 1 && m.toUser[0] == allRecipient) {
+		validateLinkedcorpIds(v, "touser", m.toUser)
+	}
+	if m.target.kind == targetLinkedcorp {
+		validateLinkedcorpIds(v, "toparty", m.toParty)
+	}
+	if len(m.toUser) > 1 {
+		for _, user := range m.toUser {
+			if user == allRecipient {
+				v.add("touser", "@all cannot be combined with other users")
+				break
+			}
+		}
+	}
+	validateIds(v, "toparty", m.toParty)
+	validateIds(v, "totag", m.toTag)
+}
+
+// validateOptions method checks the options of the message.
+func (m *Message) validateOptions(v *ValidationError) {
+	validateSwitch(v, "safe", m.safe)
+	validateSwitch(v, "enable_id_trans", m.enableIdTrans)
+	validateSwitch(v, "enable_duplicate_check", m.enableDuplicateCheck)
+	if m.target.kind == targetAppchat && m.enableDuplicateCheck != 0 {
+		v.add("enable_duplicate_check", "duplicate check is not supported by appchat")
+	}
+	if m.target.kind == targetLinkedcorp && m.enableDuplicateCheck != 0 {
+		v.add("enable_duplicate_check", "duplicate check is not supported by linkedcorp")
+	}
+	if m.target.kind == targetRobot && m.enableDuplicateCheck != 0 {
+		v.add("enable_duplicate_check", "duplicate check is not supported by robot")
+	}
+	if m.target.kind != targetRobot && (len(m.mentionedList) != 0 || len(m.mentionedMobileList) != 0) {
+		v.add("text.mentioned_list", "mentioned_list and mentioned_mobile_list are only supported by robot")
+	}
+	if m.enableDuplicateCheck == 1 &&
+		(m.duplicateCheckInterval < 0 || m.duplicateCheckInterval > maxDuplicateCheckInterval) {
+		v.add("duplicate_check_interval", "duplicate_check_interval must be between 0 and %d", maxDuplicateCheckInterval)
+	}
+}
+
+// validateContent method checks the content of the message by msg type.
+func (m *Message) validateContent(v *ValidationError) {
+	if m.target.kind == targetRobot && (m.msgType == "video" || m.msgType == "textcard") {
+		v.add("msgtype", "%s is not supported by robot", m.msgType)
+		return
+	}
+
+	switch m.msgType {
+	case "text":
+		validateRequired(v, "text.content", m.content)
+		if !m.autoSplit {
+			validateMaxBytes(v, "text.content", m.content, m.contentLimit())
+		}
+	case "image":
+		if m.target.kind != targetRobot {
+			validateRequired(v, "image.media_id", m.mediaId)
+			if len(m.imageData) != 0 {
+				v.add("image.base64", "image data is only supported by robot")
+			}
+			return
+		}
+		if len(m.imageData) == 0 {
+			v.add("image.base64", "image data cannot be empty")
+		} else if len(m.imageData) > maxRobotImageBytes {
+			v.add("image.base64", "image data cannot exceed %d bytes", maxRobotImageBytes)
+		}
+	case "voice", "file":
+		validateRequired(v, m.msgType+".media_id", m.mediaId)
+	case "video":
+		validateRequired(v, "video.media_id", m.mediaId)
+		validateMaxBytes(v, "video.title", m.title, maxTitleBytes)
+		validateMaxBytes(v, "video.description", m.description, maxDescriptionBytes)
+	case "textcard":
+		validateRequired(v, "textcard.title", m.title)
+		validateMaxBytes(v, "textcard.title", m.title, maxTitleBytes)
+		validateRequired(v, "textcard.description", m.description)
+		validateMaxBytes(v, "textcard.description", m.description, maxDescriptionBytes)
+		validateRequired(v, "textcard.url", m.url)
+		validateUrl(v, "textcard.url", m.url)
+		if utf8.RuneCountInString(m.btnTxt) > maxBtnTxtChars {
+			v.add("textcard.btntxt", "textcard.btntxt cannot exceed %d characters", maxBtnTxtChars)
+		}
+	case "news":
+		if len(m.articles) == 0 || len(m.articles) > maxNewsArticles {
+			v.add("news.articles", "news.articles must have 1 to %d articles", maxNewsArticles)
+		}
+		for i, article := range m.articles {
+			field := fmt.Sprintf("news.articles[%d]", i)
+			validateRequired(v, field+".title", article.Title)
+			validateMaxBytes(v, field+".title", article.Title, maxTitleBytes)
+			validateMaxBytes(v, field+".description", article.Description, maxDescriptionBytes)
+			if article.Appid == "" {
+				validateRequired(v, field+".url", article.Url)
+			}
+			validateUrl(v, field+".url", article.Url)
+			validateUrl(v, field+".picurl", article.Picurl)
+		}
+	case "markdown":
+		validateRequired(v, "markdown.content", m.content)
+		if !m.autoSplit {
+			validateMaxBytes(v, "markdown.content", m.content, m.contentLimit())
+		}
+	case "markdown_v2":
+		if m.target.kind != targetRobot {
+			v.add("msgtype", "markdown_v2 is only supported by robot")
+			return
+		}
+		validateRequired(v, "markdown_v2.content", m.content)
+		validateMaxBytes(v, "markdown_v2.content", m.content, m.contentLimit())
+	case "template_card":
+		if m.target.kind == targetAppchat {
+			v.add("msgtype", "template card is not supported by appchat")
+			return
+		}
+		if m.target.kind == targetLinkedcorp {
+			v.add("msgtype", "template card is not supported by linkedcorp")
+			return
+		}
+		if m.templateCard == nil {
+			v.add("template_card", "template card cannot be empty")
+			return
+		}
+		switch m.templateCard.CardType {
+		case "text_notice", "news_notice":
+		case "button_interaction", "vote_interaction", "multiple_interaction":
+			if m.target.kind == targetRobot {
+				v.add("template_card.card_type", "card type %q is not supported by robot", m.templateCard.CardType)
+			}
+		default:
+			v.add("template_card.card_type", "unsupported card type %q", m.templateCard.CardType)
+		}
+	default:
+		v.add("msgtype", "unsupported msg type")
+	}
+}
+
+// contentLimit method returns the max bytes of the content by msg type, 0 if the content is not limited.
+func (m *Message) contentLimit() int {
+	switch m.msgType {
+	case "text":
+		return maxTextContentBytes
+	case "markdown":
+		if m.target.kind == targetRobot {
+			return maxRobotMarkdownContentBytes
+		}
+		return maxMarkdownContentBytes
+	case "markdown_v2":
+		return maxRobotMarkdownContentBytes
+	default:
+		return 0
+	}
+}
+
+// validateIds method checks the ids are not empty.
+func validateIds(v *ValidationError, field string, ids []string) {
+	for _, id := range ids {
+		if strings.TrimSpace(id) == "" {
+			v.add(field, "%s cannot contain empty id", field)
+			return
+		}
+	}
+}
+
+// validateSwitch method checks the value is 0 or 1.
+func validateSwitch(v *ValidationError, field string, value int) {
+	if value != 0 && value != 1 {
+		v.add(field, "%s must be 0 or 1", field)
+	}
+}
+
+// validateRequired method checks the value is not empty.
+func validateRequired(v *ValidationError, field, value string) {
+	if value == "" {
+		v.add(field, "%s cannot be empty", field)
+	}
+}
+
+// validateMaxBytes method checks the byte length of the value.
+func validateMaxBytes(v *ValidationError, field, value string, max int) {
+	if len(value) > max {
+		v.add(field, "%s cannot exceed %d bytes", field, max)
+	}
+}
+
+// validateUrl method checks the value is a http or https url if it is not empty.
+func validateUrl(v *ValidationError, field, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(field, "%s must be a http or https url", field)
+	}
+}
@@ -0,0 +1,64 @@
+package wxcom_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mingzaily/go-wxcom"
+	"strings"
+	"testing"
+)
+
+func TestMessage_Validate(t *testing.T) {
+	err := msg.Clone().ToUser([]string{"test"}).Text(strings.Repeat("a", 2049)).Validate()
+	assertEqual(t, err.Error(), "text.content cannot exceed 2048 bytes")
+
+	err = msg.Clone().ToUser([]string{"test"}).Image("").Validate()
+	assertEqual(t, err.Error(), "image.media_id cannot be empty")
+
+	err = msg.Clone().ToUser([]string{"test"}).Markdown("markdown").Validate()
+	assertEqual(t, err, nil)
+}
+
+func TestMessage_Validate_MultiField(t *testing.T) {
+	err := msg.Clone().
+		ToUser([]string{"test", ""}).
+		Textcard("标题", strings.Repeat("描", 200), "ftp://test.com").
+		SetBtnTxt("按钮按钮按钮").
+		SetEnableIdTrans(2).
+		Validate()
+
+	var validationErr *wxcom.ValidationError
+	assertEqual(t, errors.As(err, &validationErr), true)
+
+	var fields []string
+	for _, fieldError := range validationErr.Errors {
+		fields = append(fields, fieldError.Field)
+	}
+	assertEqual(t, fields, []string{
+		"touser",
+		"enable_id_trans",
+		"textcard.description",
+		"textcard.url",
+		"textcard.btntxt",
+	})
+}
+
+func TestMessage_Validate_Recipients(t *testing.T) {
+	var users []string
+	for i := 0; i < 1001; i++ {
+		users = append(users, fmt.Sprintf("user%d", i))
+	}
+	m := msg.Clone().ToUser(users).Text("测试TEXT")
+
+	// sent in batches
+	assertEqual(t, m.Validate(), nil)
+
+	// one request
+	_, err := m.ToJson()
+	assertEqual(t, err.Error(), "touser cannot exceed 1000 users")
+}
+
+func TestMessage_Validate_DuplicateCheck(t *testing.T) {
+	err := msg.Clone().ToUser([]string{"test"}).DuplicateCheck(1, 14401).Text("测试TEXT").Validate()
+	assertEqual(t, err.Error(), "duplicate_check_interval must be between 0 and 14400")
+}
@@ -2,6 +2,7 @@ package wxcom
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-resty/resty/v2"
 	"github.com/patrickmn/go-cache"
@@ -16,12 +17,17 @@ import (
 // The resty uses go-resty/resty/v2.
 // You can refer to related documents(https://github.com/go-resty/resty) if necessary.
 type Wxcom struct {
-	corpid     string
-	corpsecret string
-	agentid    int
-	retryCount int
-	cache      *cache.Cache
-	Resty      *resty.Client
+	corpid         string
+	corpsecret     string
+	agentid        int
+	retryCount     int
+	cache          *cache.Cache
+	sendLog        SendLog
+	onSendLogError func(correlationId, msgid string, err error)
+	templates      *TemplateRegistry
+	audit          auditor
+	dedup          deduplicator
+	Resty          *resty.Client
 }
 
 type respCommon struct {
@@ -29,6 +35,25 @@ type respCommon struct {
 	Errmsg  string `json:"errmsg"`
 }
 
+// ApiError struct holds the errcode and errmsg returned by WeCom.
+type ApiError struct {
+	Errcode int
+	Errmsg  string
+}
+
+// Error method implements the error interface.
+func (e *ApiError) Error() string {
+	return fmt.Sprintf("wxcom: errcode %d, errmsg %s", e.Errcode, e.Errmsg)
+}
+
+// err method returns the ApiError if the errcode is not 0.
+func (r respCommon) err() error {
+	if r.Errcode == 0 {
+		return nil
+	}
+	return &ApiError{Errcode: r.Errcode, Errmsg: r.Errmsg}
+}
+
 type respAccessToken struct {
 	respCommon
 	AccessToken string `json:"access_token"`
@@ -47,42 +72,67 @@ func New(corpid, corpsecret string, agentid int) *Wxcom {
 	}
 }
 
-func (w *Wxcom) getAccessTokenFromServer() *respAccessToken {
-	var response *respAccessToken
+// getAccessTokenFromServer method get access token from server,
+// the *ApiError is returned if the corpid or corpsecret is invalid.
+func (w *Wxcom) getAccessTokenFromServer() (*respAccessToken, error) {
+	response := &respAccessToken{}
 
 	if w.corpid == "" && w.corpsecret == "" {
-		panic("corpid and corpsecret cannot be empty")
+		return nil, &permanentError{err: errors.New("corpid and corpsecret cannot be empty")}
 	}
 
-	_, err := w.Resty.R().
+	resp, err := w.Resty.R().
 		SetQueryParam("corpid", w.corpid).
 		SetQueryParam("corpsecret", w.corpsecret).
-		SetResult(&response).
+		SetResult(response).
 		Get("/cgi-bin/gettoken")
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	if response.Errcode != 0 {
-		panic(response.Errmsg)
+	if err = response.err(); err != nil {
+		return nil, err
 	}
 
-	return response
+	if response.AccessToken == "" {
+		return nil, fmt.Errorf("access token is empty, http status %s", resp.Status())
+	}
+
+	return response, nil
+}
+
+// sendWithRetry method posts the request, and retries when the token has expired.
+func (w *Wxcom) sendWithRetry(path string, query map[string]string, body interface{}, result interface{}) error {
+	return w.requestWithRetry(resty.MethodPost, path, query, body, result)
+}
+
+// getWithRetry method gets the request, and retries when the token has expired.
+func (w *Wxcom) getWithRetry(path string, query map[string]string, result interface{}) error {
+	return w.requestWithRetry(resty.MethodGet, path, query, nil, result)
 }
 
-// isTokenInvalidErr method check whether the token has expired.
-func (w *Wxcom) sendWithRetry(path string, query map[string]string, body map[string]interface{}, result interface{}) error {
+// requestWithRetry method does the request, and retries when the token has expired.
+func (w *Wxcom) requestWithRetry(method, path string, query map[string]string, body interface{}, result interface{}) error {
 	for i := 0; i <= w.retryCount; i++ {
 
 		resp := &respCommon{}
 
-		response, err := w.Resty.R().
+		accessToken, err := w.accessToken()
+		if err != nil {
+			return err
+		}
+
+		request := w.Resty.R().
 			SetHeader("Content-Type", "application/json; charset=UTF-8").
-			SetQueryParam("access_token", w.GetAccessToken()).
+			SetQueryParam("access_token", accessToken).
 			SetQueryParams(query).
-			SetBody(body).
 			SetResult(&result).
-			SetError(&result).Post(path)
+			SetError(&result)
+		if body != nil {
+			request.SetBody(body)
+		}
+
+		response, err := request.Execute(method, path)
 		if err != nil {
 			return err
 		}
@@ -103,17 +153,46 @@ func (w *Wxcom) sendWithRetry(path string, query map[string]string, body map[str
 }
 
 // GetAccessToken method get access token from server or cache.
+// It panics if the access token cannot be got.
 func (w *Wxcom) GetAccessToken() string {
+	accessToken, err := w.accessToken()
+	if err != nil {
+		panic(err)
+	}
+
+	return accessToken
+}
+
+// accessToken method get access token from server or cache, the error is returned instead of panic.
+func (w *Wxcom) accessToken() (string, error) {
 	var cacheKey = "access_token_" + fmt.Sprintf("%d", w.agentid)
 
 	if value, found := w.cache.Get(cacheKey); found {
-		return value.(string)
+		return value.(string), nil
 	}
 
-	resp := w.getAccessTokenFromServer()
+	resp, err := w.getAccessTokenFromServer()
+	if err != nil {
+		return "", &tokenError{err: err}
+	}
 	w.cache.Set(cacheKey, resp.AccessToken, time.Duration(resp.ExpiresIn-60)*time.Second)
 
-	return resp.AccessToken
+	return resp.AccessToken, nil
+}
+
+// tokenError struct wraps the error of getting the access token, see isRetryable for the retryable ones.
+type tokenError struct {
+	err error
+}
+
+// Error method implements the error interface.
+func (e *tokenError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap method returns the wrapped error.
+func (e *tokenError) Unwrap() error {
+	return e.err
 }
 
 // GetAgentid method get agentid from client.
@@ -124,8 +203,8 @@ func (w *Wxcom) GetAgentid() int {
 // M method creates a new Message instance.
 func (w *Wxcom) M() *Message {
 	return &Message{
-		wx:   w,
-		path: "/cgi-bin/message/send",
+		wx:     w,
+		target: agentTarget(),
 	}
 }
 
@@ -134,6 +213,20 @@ func (w *Wxcom) NewMessage() *Message {
 	return w.M()
 }
 
+// Chat method creates a new Message instance sent to the appchat by `appchat/send`.
+// The appchat message has no recipients, and does not support template card and duplicate check.
+func (w *Wxcom) Chat(chatid string) *Message {
+	return &Message{
+		wx:     w,
+		target: appchatTarget(chatid),
+	}
+}
+
+// NewChatMessage is an alias for method `Chat()`. Creates a new Message instance sent to the appchat.
+func (w *Wxcom) NewChatMessage(chatid string) *Message {
+	return w.Chat(chatid)
+}
+
 // O method creates a new Oauth instance.
 func (w *Wxcom) O() *Oauth {
 	return &Oauth{
@@ -146,3 +239,12 @@ func (w *Wxcom) O() *Oauth {
 func (w *Wxcom) NewOauth() *Oauth {
 	return w.O()
 }
+
+// NewTemplateCardUpdate method creates a new TemplateCardUpdate instance with the response code returned by sends.
+func (w *Wxcom) NewTemplateCardUpdate(responseCode string) *TemplateCardUpdate {
+	return &TemplateCardUpdate{
+		wx:           w,
+		path:         "/cgi-bin/message/update_template_card",
+		responseCode: responseCode,
+	}
+}
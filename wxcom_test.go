@@ -2,9 +2,12 @@ package wxcom_test
 
 import (
 	"github.com/mingzaily/go-wxcom"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 )
 
@@ -43,13 +46,21 @@ func assertNotEqual(t *testing.T, e, g interface{}) (r bool) {
 	return true
 }
 
+func assertJson(t *testing.T, m interface{ ToJson() (string, error) }, e string) {
+	g, err := m.ToJson()
+	if err != nil {
+		t.Errorf("Unexpected error [%v]", err)
+	}
+	assertEqual(t, e, g)
+}
+
 func equal(expected, got interface{}) bool {
 	return reflect.DeepEqual(expected, got)
 }
 
 func createTestServer(t *testing.T) *httptest.Server {
 	// for test invalid access token, retry two time.
-	time := 0
+	var time int32
 
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		t.Logf("Method: %v", r.Method)
@@ -61,12 +72,94 @@ func createTestServer(t *testing.T) *httptest.Server {
 			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"access_token\":\"token\",\"expires_in\":7200}"))
 		case "/cgi-bin/message/send":
 			w.Header().Set("Content-Type", "application/json")
-			if time > 0 {
+			body, _ := ioutil.ReadAll(r.Body)
+			if atomic.AddInt32(&time, 1) > 1 && strings.Contains(string(body), "quota_user") {
+				_, _ = w.Write([]byte("{\"errcode\":45009,\"errmsg\":\"api freq out of limit\"}"))
+			} else if atomic.LoadInt32(&time) > 1 && strings.Contains(string(body), "invalid_user") {
+				_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"invaliduser\":\"invalid_user\",\"unlicenseduser\":\"unlicensed_user\",\"msgid\":\"msgid\"}"))
+			} else if atomic.LoadInt32(&time) > 1 {
 				_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"msgid\":\"msgid\"}"))
 			} else {
 				_, _ = w.Write([]byte("{\"errcode\":42001,\"errmsg\":\"invalid access_token\"}"))
 			}
-			time++
+		case "/cgi-bin/message/update_template_card":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"invaliduser\":[\"invalid_user\"]}"))
+		case "/cgi-bin/message/recall":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\"}"))
+		case "/cgi-bin/message/get_statistics":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"statistics\":[{\"agentid\":123,\"app_name\":\"app\",\"count\":10}]}"))
+		case "/cgi-bin/agent/get":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"agentid\":123,\"allow_userinfos\":{\"user\":[{\"userid\":\"allowed_user\"}]},\"allow_partys\":{\"partyid\":[2]},\"allow_tags\":{\"tagid\":[3]}}"))
+		case "/cgi-bin/department/list":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"department\":[{\"id\":1,\"name\":\"Company\",\"parentid\":0},{\"id\":2,\"name\":\"Engineering\",\"parentid\":1},{\"id\":4,\"name\":\"SRE\",\"parentid\":2},{\"id\":5,\"name\":\"Sales\",\"parentid\":1}]}"))
+		case "/cgi-bin/user/get":
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Query().Get("userid") {
+			case "party_user":
+				_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"userid\":\"party_user\",\"department\":[4]}"))
+			case "other_user":
+				_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"userid\":\"other_user\",\"department\":[5]}"))
+			case "private_user":
+				_, _ = w.Write([]byte("{\"errcode\":60011,\"errmsg\":\"no privilege to access/modify contact/party/agent\"}"))
+			case "busy_user":
+				_, _ = w.Write([]byte("{\"errcode\":45009,\"errmsg\":\"api freq out of limit\"}"))
+			default:
+				_, _ = w.Write([]byte("{\"errcode\":60111,\"errmsg\":\"userid not found\"}"))
+			}
+		case "/cgi-bin/tag/get":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"tagname\":\"oncall\",\"userlist\":[{\"userid\":\"tag_user\",\"name\":\"tag\"}],\"partylist\":[]}"))
+		case "/cgi-bin/user/getuserid":
+			w.Header().Set("Content-Type", "application/json")
+			body, _ := ioutil.ReadAll(r.Body)
+			if strings.Contains(string(body), "13800000000") {
+				_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"userid\":\"mobile_user\"}"))
+			} else {
+				_, _ = w.Write([]byte("{\"errcode\":46004,\"errmsg\":\"user no exist\"}"))
+			}
+		case "/cgi-bin/user/get_userid_by_email":
+			w.Header().Set("Content-Type", "application/json")
+			body, _ := ioutil.ReadAll(r.Body)
+			if strings.Contains(string(body), "someone@example.com") {
+				_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"userid\":\"email_user\"}"))
+			} else {
+				_, _ = w.Write([]byte("{\"errcode\":46004,\"errmsg\":\"user no exist\"}"))
+			}
+		case "/cgi-bin/appchat/create":
+			w.Header().Set("Content-Type", "application/json")
+			body, _ := ioutil.ReadAll(r.Body)
+			if strings.Contains(string(body), "\"chatid\"") {
+				_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"chatid\":\"chat1\"}"))
+			} else {
+				_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"chatid\":\"generated\"}"))
+			}
+		case "/cgi-bin/appchat/update":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\"}"))
+		case "/cgi-bin/appchat/send":
+			w.Header().Set("Content-Type", "application/json")
+			body, _ := ioutil.ReadAll(r.Body)
+			if strings.Contains(string(body), "agentid") || !strings.Contains(string(body), "chatid") {
+				_, _ = w.Write([]byte("{\"errcode\":40003,\"errmsg\":\"invalid parameter\"}"))
+			} else {
+				_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\"}"))
+			}
+		case "/cgi-bin/linkedcorp/message/send":
+			w.Header().Set("Content-Type", "application/json")
+			body, _ := ioutil.ReadAll(r.Body)
+			if strings.Contains(string(body), "invalid_user") {
+				_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"invaliduser\":[\"corp1/invalid_user\"],\"invalidparty\":[],\"invalidtag\":[]}"))
+			} else {
+				_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"invaliduser\":[],\"invalidparty\":[],\"invalidtag\":[]}"))
+			}
+		case "/cgi-bin/appchat/get":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"chat_info\":{\"chatid\":\"" + r.URL.Query().Get("chatid") + "\",\"name\":\"oncall\",\"owner\":\"user1\",\"userlist\":[\"user1\",\"user2\"]}}"))
 		case "/cgi-bin/user/getuserinfo":
 			w.Header().Set("Content-Type", "application/json")
 			_, _ = w.Write([]byte("{\"errcode\":0,\"errmsg\":\"ok\",\"UserId\":\"test_user\",\"DeviceId\":\"device\"}"))